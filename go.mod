@@ -3,7 +3,10 @@ module github.com/utropicmedia/storj-mongodb
 go 1.13
 
 require (
-	github.com/urfave/cli v1.22.4 // indirect
+	github.com/klauspost/compress v1.9.5
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/urfave/cli v1.22.4
 	go.mongodb.org/mongo-driver v1.3.2
+	storj.io/common v0.0.0-20200406083704-0c6466fbde8b
 	storj.io/storj v1.2.1
 )
@@ -5,23 +5,42 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 	"unsafe"
-	
+
 	"github.com/utropicmedia/storj-mongodb/mongo"
 	"github.com/utropicmedia/storj-mongodb/storj"
 
+	"github.com/robfig/cron/v3"
 	"github.com/urfave/cli"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const dbConfigFile = "./config/db_property.json"
 const storjConfigFile = "./config/storj_config.json"
 
+// Layouts of the objects uploaded by the store command.
+const (
+	layoutSingle     = "single"
+	layoutCollection = "collection"
+)
+
 var gbDEBUG = false
 
 // Create command-line tool to read from CLI.
@@ -32,7 +51,7 @@ func setAppInfo() {
 	app.Name = "Storj MongoDB Connector"
 	app.Usage = "Backup your MongoDB collections to the decentralized Storj network"
 	app.Authors = []cli.Author{{Name: "Satyam Shivam - Utropicmedia", Email: "development@utropicmedia.com"}}
-	app.Version = "1.0.15"
+	app.Version = "1.1.0"
 
 }
 
@@ -43,6 +62,44 @@ func setDebug(debugVal bool) {
 	storj.DEBUG = debugVal
 }
 
+// storeFlags are the flags of the store command, which the daemon command runs.
+var storeFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "format, f",
+		Value: mongo.FormatBSON,
+		Usage: "format of the uploaded object: \"bson\" for framed BSON documents, \"archive\" for the mongodump archive format",
+	},
+	cli.StringFlag{
+		Name:  "layout, l",
+		Value: layoutSingle,
+		Usage: "\"single\" to upload one object per run, \"collection\" to upload one object per collection",
+	},
+	cli.StringSliceFlag{
+		Name:  "include",
+		Usage: "store only the collections matching the pattern: a name, a glob pattern like \"orders.*\" or a regular expression like \"/^orders_[0-9]+$/\" (repeatable)",
+	},
+	cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "store no collection matching the pattern (repeatable)",
+	},
+	cli.BoolFlag{
+		Name:  "oplog",
+		Usage: "upload the oplog entries recorded while the collections are read, which restore replays, on a replica set",
+	},
+	cli.BoolFlag{
+		Name:  "snapshot-read",
+		Usage: "read all collections as of the same cluster time, on a replica set or sharded cluster of MongoDB 5.0 or later",
+	},
+	cli.StringFlag{
+		Name:  "at-cluster-time",
+		Usage: "cluster time, as <seconds>[.<ordinal>], to read all collections as of, instead of the current one (implies --snapshot-read)",
+	},
+	cli.BoolFlag{
+		Name:  "prune",
+		Usage: "once stored, delete the snapshots of the stored databases not kept by the retention policy of the Storj configuration",
+	},
+}
+
 // setCommands sets various command-line options for the app.
 func setCommands() {
 	app.Commands = []cli.Command{
@@ -161,6 +218,7 @@ func setCommands() {
 			Aliases: []string{"s"},
 			Usage:   "Command to connect and transfer ALL collections from a desired MongoDB instance to given Storj Bucket in BSON format",
 			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing mongoDB properties in JSON format\n   if this fileName is not given, then data is read from ./config/db_property.json\n      2. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb c ./config/db_property.json ./config/storj_config.json\n",
+			Flags: storeFlags,
 			Action: func(cliContext *cli.Context) error {
 
 				// Default configuration file names.
@@ -199,35 +257,1047 @@ func setCommands() {
 					}
 				}
 
+				return runStore(cliContext, fullFileNameMongoDB, fullFileNameStorj, keyValue, restrict)
+			},
+		},
+		{
+			Name:    "restore",
+			Aliases: []string{"r"},
+			Usage:   "Command to download a BSON object from given Storj Bucket and restore its documents into a desired MongoDB instance",
+			//\n    arguments-\n      1. objectPath = full path of the object within the Storj bucket\n      2. fileName [optional] = provide full file name (with complete path), storing mongoDB properties in JSON format\n   if this fileName is not given, then data is read from ./config/db_property.json\n      3. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb r backups/testdb/2020-04-12_10:00:00.bson ./config/db_property.json ./config/storj_config.json\n",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "collection, c",
+					Usage: "name of the collection to restore the documents into, for objects without collection headers",
+				},
+				cli.BoolFlag{
+					Name:  "build-indexes-last",
+					Usage: "create the indexes of each collection after its documents are restored",
+				},
+				cli.StringSliceFlag{
+					Name:  "include",
+					Usage: "restore only the collections matching the pattern: a name, a glob pattern like \"orders.*\" or a regular expression like \"/^orders_[0-9]+$/\" (repeatable)",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude",
+					Usage: "restore no collection matching the pattern (repeatable)",
+				},
+				cli.StringFlag{
+					Name:  "at",
+					Usage: "restore the database as of the time, like \"2020-04-12_10:30:00\" or \"2020-04-12T10:30:00Z\", from the latest snapshot before it and the change segments uploaded by watch, instead of the object at the given path",
+				},
+				cli.StringFlag{
+					Name:  "source-database",
+					Usage: "name of the database whose snapshots and changes restore --at reads, if not the one restored into",
+				},
+			},
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file names.
+				var objectPath string
+				var fullFileNameMongoDB = dbConfigFile
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+
+				// process arguments - Reading object path and fileNames from the command line.
+				// A point-in-time restore finds its objects, so takes no object path.
+				if cliContext.IsSet("at") {
+					processArguments(cliContext, &fullFileNameMongoDB, &fullFileNameStorj, &keyValue)
+				} else {
+					processArguments(cliContext, &objectPath, &fullFileNameMongoDB, &fullFileNameStorj, &keyValue)
+				}
+
+				if objectPath == "" && !cliContext.IsSet("at") {
+					return errors.New("path of the object to be restored is required")
+				}
+
+				// Establish connection with Storj.
+				client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, "", storj.ClientOptions{})
+				if err != nil {
+					fmt.Printf("Failed to establish connection with Storj:\n")
+					return err
+				}
+				defer client.Close()
+
+				// Establish connection with MongoDB and get io.Writer implementor.
+				dbWriter, err := mongo.ConnectToDBWriter(fullFileNameMongoDB, cliContext.String("collection"))
+				if err != nil {
+					fmt.Printf("Failed to establish connection with MongoDB:\n")
+					return err
+				}
+				dbWriter.BuildIndexesLast = cliContext.Bool("build-indexes-last")
+				dbWriter.Filter, err = collectionFilter(cliContext, dbWriter.Filter)
+				if err != nil {
+					return err
+				}
+
+				// Download the object from the desired Storj bucket
+				// and simultaneously restore its documents into MongoDB instance.
+				// A manifest restores all data objects of its snapshot,
+				// or all databases of its run, each into its own database.
+				if cliContext.IsSet("at") {
+					var at time.Time
+					at, err = parseTime(cliContext.String("at"))
+					if err != nil {
+						return err
+					}
+					sourceDatabase := cliContext.String("source-database")
+					if sourceDatabase == "" {
+						sourceDatabase = dbWriter.DatabaseName
+					}
+					err = restoreAt(dbWriter, sourceDatabase, at, client)
+				} else if storj.IsManifestPath(objectPath) {
+					var manifest *storj.Manifest
+					manifest, err = client.DownloadManifest(context.TODO(), objectPath)
+					if err == nil && len(manifest.Databases) > 0 {
+						return restoreDatabases(dbWriter, manifest, objectPath, client)
+					}
+					if err == nil {
+						err = restoreSnapshot(dbWriter, manifest, objectPath, client)
+					}
+				} else {
+					_, err = client.DownloadData(context.TODO(), objectPath, dbWriter)
+					if err == nil {
+						err = dbWriter.Close()
+					}
+				}
+				if err != nil {
+					fmt.Printf("Error while downloading the object and restoring its documents to MongoDB:")
+					return err
+				}
+
+				fmt.Printf("Restored %d documents into %s database\n", dbWriter.DocumentCount, dbWriter.DatabaseName)
+				return nil
+			},
+		},
+		{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "Command to watch the changes of a desired MongoDB database and upload them to given Storj Bucket in segments, until interrupted",
+			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing mongoDB properties in JSON format\n   if this fileName is not given, then data is read from ./config/db_property.json\n      2. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb w ./config/db_property.json ./config/storj_config.json\n",
+			Flags: []cli.Flag{
+				cli.Int64Flag{
+					Name:  "segment-events",
+					Value: 1000,
+					Usage: "largest number of change events of a segment",
+				},
+				cli.DurationFlag{
+					Name:  "segment-interval",
+					Value: time.Minute,
+					Usage: "longest time the change events of a segment are collected for",
+				},
+				cli.StringSliceFlag{
+					Name:  "include",
+					Usage: "watch only the collections matching the pattern: a name, a glob pattern like \"orders.*\" or a regular expression like \"/^orders_[0-9]+$/\" (repeatable)",
+				},
+				cli.StringSliceFlag{
+					Name:  "exclude",
+					Usage: "watch no collection matching the pattern (repeatable)",
+				},
+			},
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file names.
+				var fullFileNameMongoDB = dbConfigFile
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+
+				// process arguments - Reading fileNames from the command line.
+				processArguments(cliContext, &fullFileNameMongoDB, &fullFileNameStorj, &keyValue)
+
+				// Establish connection with Storj.
+				client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, "", storj.ClientOptions{CreateBucket: true})
+				if err != nil {
+					fmt.Printf("Failed to establish connection with Storj:\n")
+					return err
+				}
+				defer client.Close()
+
 				// Establish connection with MongoDB and get io.Reader implementor.
 				dbReader, err := mongo.ConnectToDB(fullFileNameMongoDB)
-
 				if err != nil {
 					fmt.Printf("Failed to establish connection with MongoDB:\n")
 					return err
 				}
-				// Fetch all collections' documents from MongoDB instance
-				// and simultaneously store them into desired Storj bucket.
-				scope, err := storj.ConnectStorjReadUploadData(fullFileNameStorj, dbReader, dbReader.DatabaseName, keyValue, restrict)
+				dbReader.Filter, err = collectionFilter(cliContext, dbReader.Filter)
+				if err != nil {
+					return err
+				}
+				// Change events hold full documents, which the queries could not select.
+				if len(dbReader.Queries) > 0 {
+					return fmt.Errorf("cannot watch %s database with queries, as its change events would upload the documents and fields they leave out", dbReader.DatabaseName)
+				}
+
+				// Stop watching once interrupted, after uploading the changes read so far.
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				interrupts := make(chan os.Signal, 1)
+				signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+				go func() {
+					<-interrupts
+					fmt.Println("Interrupted, stopping to watch changes...")
+					cancel()
+				}()
+
+				err = watchDatabase(ctx, dbReader, cliContext.Int64("segment-events"), cliContext.Duration("segment-interval"), client)
+				if err != nil && err != context.Canceled {
+					fmt.Printf("Error while watching MongoDB changes and uploading them to bucket:")
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "daemon",
+			Aliases: []string{"d"},
+			Usage:   "Command to run store on a cron schedule until interrupted, logging the result of each run",
+			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing mongoDB properties in JSON format\n   if this fileName is not given, then data is read from ./config/db_property.json\n      2. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb d --schedule "0 3 * * *" ./config/db_property.json ./config/storj_config.json\n",
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "schedule",
+					Usage: "cron expression, like \"0 3 * * *\" or \"@every 6h\", of the store runs, instead of the \"schedule\" key of the Storj configuration",
+				},
+			}, storeFlags...),
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file names.
+				var fullFileNameMongoDB = dbConfigFile
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+				var restrict string
+
+				// process arguments - Reading fileNames from the command line.
+				processArguments(cliContext, &fullFileNameMongoDB, &fullFileNameStorj, &keyValue, &restrict)
+
+				schedule := cliContext.String("schedule")
+				if schedule == "" {
+					configStorj, err := storj.LoadStorjConfiguration(fullFileNameStorj)
+					if err != nil {
+						return err
+					}
+					schedule = configStorj.Schedule
+				}
+				if schedule == "" {
+					return errors.New("no schedule given, set the \"schedule\" key of the Storj configuration or use --schedule")
+				}
+
+				return runDaemon(cliContext, schedule, fullFileNameMongoDB, fullFileNameStorj, keyValue, restrict)
+			},
+		},
+		{
+			Name:    "verify",
+			Aliases: []string{"v"},
+			Usage:   "Command to download a snapshot from given Storj Bucket and check its objects and documents against its manifest, failing on any mismatch",
+			//\n    arguments-\n      1. manifestPath = full path of the snapshot's manifest within the Storj bucket\n      2. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb v backups/testdb/2020-04-12_10:00:00.manifest.json ./config/storj_config.json\n",
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file name.
+				var manifestPath string
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+
+				// process arguments - Reading manifest path and fileName from the command line.
+				processArguments(cliContext, &manifestPath, &fullFileNameStorj, &keyValue)
+
+				if !storj.IsManifestPath(manifestPath) {
+					return errors.New("path of the manifest of the snapshot to be verified is required")
+				}
+
+				// Establish connection with Storj.
+				client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, "", storj.ClientOptions{})
+				if err != nil {
+					fmt.Printf("Failed to establish connection with Storj:\n")
+					return err
+				}
+				defer client.Close()
+
+				manifest, err := client.DownloadManifest(context.TODO(), manifestPath)
 				if err != nil {
-					fmt.Printf("Error while fetching MongoDB documents and uploading them to bucket:")
 					return err
 				}
-				fmt.Println(" ")
-				if keyValue == "key" {
-					if restrict == "restrict" {
-						fmt.Println("Restricted Serialized Scope Key: ", scope)
-						fmt.Println(" ")
-					} else {
-						fmt.Println("Serialized Scope Key: ", scope)
-						fmt.Println(" ")
+
+				// A run storing several databases is verified database by database.
+				snapshotPaths := []string{manifestPath}
+				snapshotManifests := []*storj.Manifest{manifest}
+				if len(manifest.Databases) > 0 {
+					snapshotPaths, snapshotManifests = nil, nil
+					for _, snapshot := range manifest.Databases {
+						databaseManifestPath := storj.DatabaseManifestPath(manifestPath, snapshot)
+						databaseManifest, err := client.DownloadManifest(context.TODO(), databaseManifestPath)
+						if err != nil {
+							return err
+						}
+						snapshotPaths = append(snapshotPaths, databaseManifestPath)
+						snapshotManifests = append(snapshotManifests, databaseManifest)
 					}
 				}
-				return err
+
+				var mismatches []error
+				for i, snapshotManifest := range snapshotManifests {
+					snapshotMismatches, err := verifySnapshot(snapshotManifest, snapshotPaths[i], client)
+					if err != nil {
+						mismatches = append(mismatches, fmt.Errorf("snapshot %s: %v", snapshotManifest.Snapshot, err))
+					}
+					mismatches = append(mismatches, snapshotMismatches...)
+				}
+
+				if len(mismatches) > 0 {
+					for _, mismatch := range mismatches {
+						log.Printf("Mismatch: %s\n", mismatch)
+					}
+					return fmt.Errorf("verification of %s failed with %d mismatches", manifestPath, len(mismatches))
+				}
+
+				fmt.Printf("Verified %d snapshots of %s: OK\n", len(snapshotManifests), manifestPath)
+				return nil
+			},
+		},
+		{
+			Name:    "prune",
+			Aliases: []string{"pr"},
+			Usage:   "Command to delete the snapshots in given Storj Bucket not kept by a grandfather-father-son retention policy",
+			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb pr --dry-run ./config/storj_config.json\n",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "database",
+					Usage: "prune only the snapshots of the database",
+				},
+				cli.IntFlag{
+					Name:  "keep-hourly",
+					Usage: "keep the latest snapshot of each of the last N hours, instead of the configured number",
+				},
+				cli.IntFlag{
+					Name:  "keep-daily",
+					Usage: "keep the latest snapshot of each of the last N days, instead of the configured number",
+				},
+				cli.IntFlag{
+					Name:  "keep-weekly",
+					Usage: "keep the latest snapshot of each of the last N weeks, instead of the configured number",
+				},
+				cli.IntFlag{
+					Name:  "keep-monthly",
+					Usage: "keep the latest snapshot of each of the last N months, instead of the configured number",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the snapshots that would be deleted, without deleting them",
+				},
+			},
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file name.
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+
+				// process arguments - Reading fileName from the command line.
+				processArguments(cliContext, &fullFileNameStorj, &keyValue)
+
+				// Establish connection with Storj.
+				client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, "", storj.ClientOptions{})
+				if err != nil {
+					fmt.Printf("Failed to establish connection with Storj:\n")
+					return err
+				}
+				defer client.Close()
+
+				policy := client.Config().Retention
+				for flag, keep := range map[string]*int{
+					"keep-hourly":  &policy.Hourly,
+					"keep-daily":   &policy.Daily,
+					"keep-weekly":  &policy.Weekly,
+					"keep-monthly": &policy.Monthly,
+				} {
+					if cliContext.IsSet(flag) {
+						*keep = cliContext.Int(flag)
+					}
+				}
+
+				err = pruneSnapshots(cliContext.String("database"), &policy, cliContext.Bool("dry-run"), client)
+				if err != nil {
+					fmt.Printf("Error while pruning the snapshots of the bucket:")
+					return err
+				}
+				return nil
 			},
 		},
+		{
+			Name:    "list",
+			Aliases: []string{"l"},
+			Usage:   "Command to list the snapshots uploaded to given Storj Bucket, with their number of objects, size and age",
+			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_mongodb l ./config/storj_config.json\n",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "database",
+					Usage: "list only the snapshots of the database",
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print the snapshots as JSON",
+				},
+			},
+			Action: func(cliContext *cli.Context) error {
+
+				// Default configuration file name.
+				var fullFileNameStorj = storjConfigFile
+				var keyValue string
+
+				// process arguments - Reading fileName from the command line.
+				processArguments(cliContext, &fullFileNameStorj, &keyValue)
+
+				// Keep stdout for the JSON document only, printing the progress
+				// messages to stderr instead.
+				var progress io.Writer = os.Stdout
+				if cliContext.Bool("json") {
+					progress = os.Stderr
+				}
+
+				// Establish connection with Storj.
+				client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, "", storj.ClientOptions{Log: progress})
+				if err != nil {
+					fmt.Fprintf(progress, "Failed to establish connection with Storj:\n")
+					return err
+				}
+				defer client.Close()
+
+				snapshots, err := client.ListSnapshots(context.TODO(), cliContext.String("database"))
+				if err != nil {
+					fmt.Fprintf(progress, "Error while listing the objects of the bucket:")
+					return err
+				}
+
+				if cliContext.Bool("json") {
+					snapshotsJSON, err := json.MarshalIndent(snapshots, "", "\t")
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(snapshotsJSON))
+					return nil
+				}
+
+				writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(writer, "DATABASE\tTIMESTAMP\tOBJECTS\tSIZE\tAGE\tMANIFEST")
+				for _, snapshot := range snapshots {
+					// The manifest of a run storing several databases has no database.
+					database := "-"
+					if snapshot.Database != "" {
+						database = snapshot.Database
+					}
+					manifest := "-"
+					if snapshot.Manifest != "" {
+						manifest = snapshot.Manifest
+					}
+					fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\n", database, path.Base(snapshot.Snapshot),
+						snapshot.Objects, formatSize(snapshot.Size), formatAge(time.Duration(snapshot.AgeSeconds)*time.Second), manifest)
+				}
+				return writer.Flush()
+			},
+		},
+	}
+}
+
+// watchDatabase uploads the changes of the database read by dbReader
+// in segments of up to segmentEvents events, or of segmentInterval,
+// resuming after the last segment uploaded by an earlier run.
+func watchDatabase(ctx context.Context, dbReader *mongo.MongoReader, segmentEvents int64, segmentInterval time.Duration, client *storj.Client) error {
+	state, err := client.DownloadChangeState(ctx, dbReader.DatabaseName)
+	if err != nil {
+		return err
+	}
+
+	var resumeToken bson.Raw
+	if state != nil {
+		var token bson.D
+		if err := bson.UnmarshalExtJSON(state.ResumeToken, false, &token); err != nil {
+			return fmt.Errorf("could not parse resume token: %v", err)
+		}
+		if resumeToken, err = bson.Marshal(token); err != nil {
+			return err
+		}
+		fmt.Printf("Resuming to watch changes of %s database after %s\n", dbReader.DatabaseName, state.LastSegment.Path)
+	} else {
+		fmt.Printf("Watching changes of %s database from now on\n", dbReader.DatabaseName)
+	}
+
+	changeStream, err := dbReader.WatchChanges(ctx, resumeToken)
+	if err != nil {
+		return err
+	}
+	defer changeStream.Close(context.Background())
+
+	for {
+		batch, err := changeStream.NextBatch(ctx, segmentEvents, segmentInterval)
+		if batch.Events > 0 {
+			tokenJSON, jsonErr := extJSON(batch.ResumeToken)
+			if jsonErr != nil {
+				return jsonErr
+			}
+			// Upload the batch read so far even once interrupted.
+			_, uploadErr := client.UploadChanges(context.Background(), bytes.NewReader(batch.Data), dbReader.DatabaseName, storj.ChangeSegment{
+				First:  storj.ClusterTime{T: batch.First.T, I: batch.First.I},
+				Last:   storj.ClusterTime{T: batch.Last.T, I: batch.Last.I},
+				Events: batch.Events,
+			}, tokenJSON)
+			if uploadErr != nil {
+				return uploadErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// storeDatabases uploads each database read by dbReaders under its own path,
+// as storeDatabase does, followed by a manifest listing their snapshots.
+func storeDatabases(dbReaders []*mongo.MongoReader, format string, layout string, captureOplog bool, client *storj.Client) error {
+	serverVersion, err := dbReaders[0].ServerVersion(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	manifest := &storj.Manifest{
+		ToolVersion:   app.Version,
+		ServerVersion: serverVersion,
+		Format:        format,
+		Layout:        layout,
+		Snapshot:      startTime.Format("2006-01-02_15:04:05"),
+		StartTime:     startTime.UTC(),
+		AtClusterTime: manifestClusterTime(dbReaders[0]),
+	}
+
+	for _, dbReader := range dbReaders {
+		fmt.Printf("Storing MongoDB database %s...\n", dbReader.DatabaseName)
+
+		dbReader.Format = format
+		databaseManifest, err := storeDatabase(dbReader, layout, captureOplog, client)
+		if err != nil {
+			return err
+		}
+		manifest.Databases = append(manifest.Databases, databaseManifest.Snapshot)
+	}
+	manifest.EndTime = time.Now().UTC()
+
+	_, err = client.UploadManifest(context.TODO(), manifest)
+	return err
+}
+
+// restoreDatabases restores the snapshot of each database listed by the manifest
+// at manifestPath into the database of the same name.
+func restoreDatabases(dbWriter *mongo.MongoWriter, manifest *storj.Manifest, manifestPath string, client *storj.Client) error {
+	for _, snapshot := range manifest.Databases {
+		databaseManifestPath := storj.DatabaseManifestPath(manifestPath, snapshot)
+		databaseWriter := dbWriter.ForDatabase(path.Dir(snapshot))
+
+		databaseManifest, err := client.DownloadManifest(context.TODO(), databaseManifestPath)
+		if err == nil {
+			err = restoreSnapshot(databaseWriter, databaseManifest, databaseManifestPath, client)
+		}
+		if err != nil {
+			fmt.Printf("Error while downloading the snapshot %s and restoring its documents to MongoDB:", snapshot)
+			return err
+		}
+
+		fmt.Printf("Restored %d documents into %s database\n", databaseWriter.DocumentCount, databaseWriter.DatabaseName)
+	}
+	return nil
+}
+
+// restoreSnapshot restores the data objects of the manifest at manifestPath,
+// then replays the oplog entries recorded while they were read, if any.
+func restoreSnapshot(dbWriter *mongo.MongoWriter, manifest *storj.Manifest, manifestPath string, client *storj.Client) error {
+	_, err := client.DownloadSnapshot(context.TODO(), manifestPath, dbWriter)
+	if err == nil {
+		err = dbWriter.Close()
+	}
+	if err != nil || manifest.Oplog == nil {
+		return err
+	}
+
+	fmt.Printf("Replaying oplog of %s database...\n", manifest.Database)
+	oplogWriter := dbWriter.OplogWriter(manifest.Database)
+	_, err = client.DownloadData(context.TODO(), storj.SnapshotObjectPath(manifestPath, manifest.Oplog.Path), oplogWriter)
+	if err == nil {
+		err = oplogWriter.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replayed %d oplog entries into %s database\n", oplogWriter.EntryCount, oplogWriter.DatabaseName)
+	return nil
+}
+
+// runStore stores the databases of the MongoDB configuration as the flags of
+// the store command tell, and prunes their snapshots if asked to.
+func runStore(cliContext *cli.Context, fullFileNameMongoDB string, fullFileNameStorj string, keyValue string, restrict string) error {
+	format := cliContext.String("format")
+	if format != mongo.FormatBSON && format != mongo.FormatArchive {
+		return fmt.Errorf("unknown format %q, use %q or %q", format, mongo.FormatBSON, mongo.FormatArchive)
 	}
+	layout := cliContext.String("layout")
+	if layout != layoutSingle && layout != layoutCollection {
+		return fmt.Errorf("unknown layout %q, use %q or %q", layout, layoutSingle, layoutCollection)
+	}
+
+	// Establish connection with MongoDB and get an io.Reader implementor
+	// of each database to be stored.
+	dbReaders, err := mongo.ConnectToDatabases(fullFileNameMongoDB)
+
+	if err != nil {
+		fmt.Printf("Failed to establish connection with MongoDB:\n")
+		return err
+	}
+	// The readers share the connection, which a daemon must not keep across runs.
+	if len(dbReaders) > 0 {
+		defer dbReaders[0].Disconnect(context.TODO())
+	}
+	if len(dbReaders) == 0 {
+		return errors.New("no database to be stored")
+	}
+	for _, dbReader := range dbReaders {
+		dbReader.Filter, err = collectionFilter(cliContext, dbReader.Filter)
+		if err != nil {
+			return err
+		}
+		// The oplog records full documents, which the queries could not select.
+		if cliContext.Bool("oplog") && len(dbReader.Queries) > 0 {
+			return fmt.Errorf("--oplog cannot be used with the queries of %s database, as its oplog entries would upload the documents and fields they leave out", dbReader.DatabaseName)
+		}
+	}
+	if cliContext.Bool("snapshot-read") || cliContext.IsSet("at-cluster-time") {
+		// Read all databases as of the same cluster time.
+		atClusterTime, err := clusterTime(cliContext, dbReaders[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Reading MongoDB as of cluster time", atClusterTime.T, atClusterTime.I)
+		for _, dbReader := range dbReaders {
+			dbReader.SnapshotRead = true
+			dbReader.AtClusterTime = atClusterTime
+		}
+	}
+
+	// Connect to the Storj bucket once for all uploads of the run.
+	client, err := storj.ConnectClient(context.TODO(), fullFileNameStorj, keyValue, restrict, storj.ClientOptions{CreateBucket: true})
+	if err != nil {
+		fmt.Printf("Failed to establish connection with Storj:\n")
+		return err
+	}
+	defer client.Close()
+
+	// Fetch all collections' documents from MongoDB instance
+	// and simultaneously store them into desired Storj bucket.
+	if len(dbReaders) == 1 {
+		dbReaders[0].Format = format
+		_, err = storeDatabase(dbReaders[0], layout, cliContext.Bool("oplog"), client)
+	} else {
+		err = storeDatabases(dbReaders, format, layout, cliContext.Bool("oplog"), client)
+	}
+	if err != nil {
+		fmt.Printf("Error while fetching MongoDB documents and uploading them to bucket:")
+		return err
+	}
+	if cliContext.Bool("prune") {
+		for _, dbReader := range dbReaders {
+			if err := pruneSnapshots(dbReader.DatabaseName, nil, false, client); err != nil {
+				fmt.Printf("Error while pruning the snapshots of %s database:", dbReader.DatabaseName)
+				return err
+			}
+		}
+	}
+	fmt.Println(" ")
+	if keyValue == "key" {
+		if restrict == "restrict" {
+			fmt.Println("Restricted Serialized Scope Key: ", client.Scope())
+			fmt.Println(" ")
+		} else {
+			fmt.Println("Serialized Scope Key: ", client.Scope())
+			fmt.Println(" ")
+		}
+	}
+	return err
+}
+
+// runDaemon runs store on the cron schedule until interrupted. A run starting
+// while the previous one is still running is skipped, and a failed run is
+// logged without stopping the later ones.
+func runDaemon(cliContext *cli.Context, schedule string, fullFileNameMongoDB string, fullFileNameStorj string, keyValue string, restrict string) error {
+	logger := cron.PrintfLogger(log.New(os.Stderr, "", log.LstdFlags))
+	scheduler := cron.New(cron.WithChain(cron.Recover(logger), cron.SkipIfStillRunning(logger)))
+
+	var run int
+	var entryID cron.EntryID
+	entryID, err := scheduler.AddFunc(schedule, func() {
+		run++
+		startTime := time.Now()
+		log.Printf("Store run %d: started\n", run)
+
+		err := runStore(cliContext, fullFileNameMongoDB, fullFileNameStorj, keyValue, restrict)
+		duration := time.Since(startTime).Round(time.Second)
+		if err != nil {
+			log.Printf("Store run %d: failed after %s: %s\n", run, duration, err)
+		} else {
+			log.Printf("Store run %d: succeeded in %s\n", run, duration)
+		}
+		log.Printf("Next store run at %s\n", scheduler.Entry(entryID).Next.Format(time.RFC3339))
+	})
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %v", schedule, err)
+	}
+
+	scheduler.Start()
+	log.Printf("Running store on schedule %q, next run at %s\n", schedule, scheduler.Entry(entryID).Next.Format(time.RFC3339))
+
+	// Stop once interrupted, after the running store, if any, is done.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	<-interrupts
+	log.Println("Interrupted, waiting for the running store to finish...")
+	<-scheduler.Stop().Done()
+	return nil
+}
+
+// pruneSnapshots deletes the snapshots of the database, or of all databases if
+// not given, that are not kept by the retention policy, or by the one of the
+// Storj configuration if policy is nil. With dryRun, they are listed only.
+func pruneSnapshots(databaseName string, policy *storj.RetentionPolicy, dryRun bool, client *storj.Client) error {
+	pruned, err := client.PruneSnapshots(context.TODO(), databaseName, policy, dryRun)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, snapshot := range pruned {
+		size += snapshot.Size
+	}
+	if dryRun {
+		fmt.Printf("Would delete %d snapshots, %s\n", len(pruned), formatSize(size))
+	} else {
+		fmt.Printf("Deleted %d snapshots, %s\n", len(pruned), formatSize(size))
+	}
+	return nil
 }
+
+// verifySnapshot downloads the objects of the snapshot of the manifest at
+// manifestPath, and returns the ones not matching their manifest, along with
+// the invalid BSON documents and the collections whose documents do not.
+func verifySnapshot(manifest *storj.Manifest, manifestPath string, client *storj.Client) ([]error, error) {
+	// A mongodump archive is checked against the checksums of its objects only.
+	if manifest.Format == mongo.FormatArchive {
+		return client.VerifySnapshot(context.TODO(), manifestPath, manifest, ioutil.Discard)
+	}
+
+	validator := &mongo.DocumentValidator{}
+	mismatches, err := client.VerifySnapshot(context.TODO(), manifestPath, manifest, validator)
+	if err == nil {
+		err = validator.Close()
+	}
+	if err != nil {
+		return mismatches, err
+	}
+
+	documents := map[string]mongo.CollectionStats{}
+	for _, collectionStats := range validator.Collections {
+		documents[collectionStats.Name] = collectionStats
+	}
+	for _, collection := range manifest.Collections {
+		collectionStats, ok := documents[collection.Name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Errorf("snapshot %s: collection %s is missing", manifest.Snapshot, collection.Name))
+		case collectionStats.Documents != collection.Documents:
+			mismatches = append(mismatches, fmt.Errorf("snapshot %s: collection %s has %d documents, manifest records %d", manifest.Snapshot, collection.Name, collectionStats.Documents, collection.Documents))
+		case collectionStats.Bytes != collection.Bytes:
+			mismatches = append(mismatches, fmt.Errorf("snapshot %s: collection %s has %d bytes of documents, manifest records %d", manifest.Snapshot, collection.Name, collectionStats.Bytes, collection.Bytes))
+		}
+		delete(documents, collection.Name)
+	}
+	if len(manifest.Collections) > 0 {
+		for name := range documents {
+			mismatches = append(mismatches, fmt.Errorf("snapshot %s: collection %s is not recorded by the manifest", manifest.Snapshot, name))
+		}
+	}
+
+	fmt.Printf("Checked %d documents of %d collections of snapshot %s\n", validator.DocumentCount, len(validator.Collections), manifest.Snapshot)
+	return mismatches, nil
+}
+
+// restoreAt restores the sourceDatabase as of the given time: the latest snapshot
+// consistent before it, then the change events recorded after the snapshot, up to it.
+func restoreAt(dbWriter *mongo.MongoWriter, sourceDatabase string, at time.Time, client *storj.Client) error {
+	manifest, manifestPath, err := client.FindSnapshot(context.TODO(), sourceDatabase, at)
+	if err != nil {
+		return err
+	}
+	segments, err := client.ListChangeSegments(context.TODO(), sourceDatabase)
+	if err != nil {
+		return err
+	}
+
+	after := manifest.ChangesAfter()
+	until := storj.ClusterTime{T: uint32(at.Unix()), I: math.MaxUint32}
+	if len(segments) == 0 || segments[0].First.After(after) {
+		fmt.Printf("Warning: no changes of %s database were uploaded right after snapshot %s, changes made in between are not restored\n", sourceDatabase, manifest.Snapshot)
+	}
+	if len(segments) > 0 && until.After(segments[len(segments)-1].Last) {
+		fmt.Printf("Warning: the uploaded changes of %s database end before %s, changes made in between are not restored\n", sourceDatabase, at.Format(time.RFC3339))
+	}
+
+	fmt.Printf("Restoring snapshot %s...\n", manifest.Snapshot)
+	if err := restoreSnapshot(dbWriter, manifest, manifestPath, client); err != nil {
+		return err
+	}
+
+	changeWriter := dbWriter.ChangeWriter(sourceDatabase,
+		primitive.Timestamp{T: after.T, I: after.I}, primitive.Timestamp{T: until.T, I: until.I})
+	for _, segment := range segments {
+		if !segment.Last.After(after) || segment.First.After(until) {
+			continue
+		}
+		if _, err := client.DownloadData(context.TODO(), segment.Path, changeWriter); err != nil {
+			return err
+		}
+	}
+	if err := changeWriter.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Applied %d change events into %s database, up to %s\n", changeWriter.EventCount, changeWriter.DatabaseName, at.Format(time.RFC3339))
+	return nil
+}
+
+// formatSize returns the number of bytes in a human-readable unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
+
+// formatAge returns the duration in its two largest units, e.g. "3d4h".
+func formatAge(age time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case age >= day:
+		return fmt.Sprintf("%dd%dh", age/day, age%day/time.Hour)
+	case age >= time.Hour:
+		return fmt.Sprintf("%dh%dm", age/time.Hour, age%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", age/time.Minute)
+	}
+}
+
+// parseTime parses a time given as RFC 3339, or in local time
+// in the format of the uploaded objects' names.
+func parseTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02_15:04:05", value, time.Local)
+	if err != nil {
+		return parsed, fmt.Errorf("invalid time %q, expected a time like \"2020-04-12_10:30:00\" or \"2020-04-12T10:30:00Z\"", value)
+	}
+	return parsed, nil
+}
+
+// storeDatabase uploads the collections of the database read by dbReader
+// to the Storj bucket in the given layout, followed by the manifest of the
+// snapshot. With captureOplog, the oplog entries recorded while the collections
+// were read are uploaded too.
+// It returns the manifest of the snapshot.
+func storeDatabase(dbReader *mongo.MongoReader, layout string, captureOplog bool, client *storj.Client) (*storj.Manifest, error) {
+	serverVersion, err := dbReader.ServerVersion(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	var oplogStart primitive.Timestamp
+	if captureOplog && dbReader.SnapshotRead {
+		// Start the oplog at the cluster time the collections are read as of,
+		// so that no write is left out of both the snapshot and the oplog.
+		if dbReader.AtClusterTime.T == 0 {
+			dbReader.AtClusterTime, err = dbReader.ClusterTime(context.TODO())
+			if err != nil {
+				return nil, err
+			}
+		}
+		oplogStart = dbReader.AtClusterTime
+	} else if captureOplog {
+		oplogStart, err = dbReader.OplogTime(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := &storj.Manifest{
+		ToolVersion:   app.Version,
+		Database:      dbReader.DatabaseName,
+		ServerVersion: serverVersion,
+		Format:        dbReader.Format,
+		Layout:        layout,
+		StartTime:     time.Now().UTC(),
+	}
+
+	if layout == layoutCollection {
+		collectionReaders, err := dbReader.CollectionReaders()
+		if err != nil {
+			return nil, err
+		}
+		var collectionObjects []storj.CollectionObject
+		for _, collectionReader := range collectionReaders {
+			collectionObjects = append(collectionObjects, storj.CollectionObject{Name: collectionReader.CollectionName, Reader: collectionReader})
+		}
+		err = client.UploadCollections(context.TODO(), collectionObjects, dbReader.DatabaseName, dbReader.FileExtension(), manifest)
+		if err != nil {
+			return manifest, err
+		}
+		for i, collectionReader := range collectionReaders {
+			for _, collectionStats := range collectionReader.Stats() {
+				manifestCollection, err := newManifestCollection(collectionStats)
+				if err != nil {
+					return manifest, err
+				}
+				manifestCollection.Object = manifest.Objects[i].Path
+				manifest.Collections = append(manifest.Collections, manifestCollection)
+			}
+		}
+	} else {
+		err = client.UploadData(context.TODO(), dbReader, dbReader.DatabaseName, dbReader.FileExtension(), manifest)
+		if err != nil {
+			return manifest, err
+		}
+		for _, collectionStats := range dbReader.Stats() {
+			manifestCollection, err := newManifestCollection(collectionStats)
+			if err != nil {
+				return manifest, err
+			}
+			manifest.Collections = append(manifest.Collections, manifestCollection)
+		}
+	}
+	if captureOplog {
+		if err := storeOplog(dbReader, oplogStart, manifest, client); err != nil {
+			return manifest, err
+		}
+	}
+	manifest.EndTime = time.Now().UTC()
+	manifest.AtClusterTime = manifestClusterTime(dbReader)
+
+	_, err = client.UploadManifest(context.TODO(), manifest)
+	return manifest, err
+}
+
+// storeOplog uploads the oplog entries about the database read by dbReader,
+// from oplogStart up to now, next to the data objects of the manifest.
+func storeOplog(dbReader *mongo.MongoReader, oplogStart primitive.Timestamp, manifest *storj.Manifest, client *storj.Client) error {
+	oplogEnd, err := dbReader.OplogTime(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Reading oplog of %s database...\n", dbReader.DatabaseName)
+	oplogReader := dbReader.OplogReader(oplogStart, oplogEnd)
+	if err := client.UploadOplog(context.TODO(), oplogReader, manifest); err != nil {
+		return err
+	}
+	manifest.Oplog.Start = storj.ClusterTime{T: oplogStart.T, I: oplogStart.I}
+	manifest.Oplog.End = storj.ClusterTime{T: oplogEnd.T, I: oplogEnd.I}
+	manifest.Oplog.Entries = oplogReader.EntryCount
+	return nil
+}
+
+// newManifestCollection describes a collection read into a snapshot for its manifest.
+func newManifestCollection(collectionStats mongo.CollectionStats) (storj.ManifestCollection, error) {
+	manifestCollection := storj.ManifestCollection{
+		Name:      collectionStats.Name,
+		Type:      collectionStats.Type,
+		Documents: collectionStats.Documents,
+		Bytes:     collectionStats.Bytes,
+	}
+	var err error
+	manifestCollection.Options, err = extJSON(collectionStats.Options)
+	if err != nil {
+		return manifestCollection, err
+	}
+	manifestCollection.Filter, err = extJSON(collectionStats.Filter)
+	if err != nil {
+		return manifestCollection, err
+	}
+	manifestCollection.Projection, err = extJSON(collectionStats.Projection)
+	return manifestCollection, err
+}
+
+// extJSON converts a BSON document to relaxed MongoDB extended JSON,
+// or to nil when it is empty.
+func extJSON(document bson.Raw) (json.RawMessage, error) {
+	if len(document) == 0 {
+		return nil, nil
+	}
+	return bson.MarshalExtJSON(document, false, false)
+}
+
+// clusterTime returns the cluster time given by the --at-cluster-time flag,
+// or else the current cluster time of the database read by dbReader.
+func clusterTime(cliContext *cli.Context, dbReader *mongo.MongoReader) (primitive.Timestamp, error) {
+	if !cliContext.IsSet("at-cluster-time") {
+		return dbReader.ClusterTime(context.TODO())
+	}
+
+	value := cliContext.String("at-cluster-time")
+	parts := strings.SplitN(value, ".", 2)
+	seconds, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("invalid cluster time %q: %v", value, err)
+	}
+	var ordinal uint64
+	if len(parts) == 2 {
+		ordinal, err = strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return primitive.Timestamp{}, fmt.Errorf("invalid cluster time %q: %v", value, err)
+		}
+	}
+	return primitive.Timestamp{T: uint32(seconds), I: uint32(ordinal)}, nil
+}
+
+// manifestClusterTime returns the cluster time the database was read at
+// for its manifest, or nil unless it was read as of one.
+func manifestClusterTime(dbReader *mongo.MongoReader) *storj.ClusterTime {
+	if !dbReader.SnapshotRead {
+		return nil
+	}
+	return &storj.ClusterTime{T: dbReader.AtClusterTime.T, I: dbReader.AtClusterTime.I}
+}
+
+// collectionFilter adds the collection patterns given by the --include and
+// --exclude flags to the ones of the filter.
+func collectionFilter(cliContext *cli.Context, filter mongo.CollectionFilter) (mongo.CollectionFilter, error) {
+	filter.Include = append(filter.Include, cliContext.StringSlice("include")...)
+	filter.Exclude = append(filter.Exclude, cliContext.StringSlice("exclude")...)
+	return filter, filter.Check()
+}
+
+// processArguments assigns the command-line arguments, in their order, to
+// the given values and turns on debug mode if debug is given as argument.
+// Values without a matching argument keep their defaults.
+func processArguments(cliContext *cli.Context, values ...*string) {
+	var next = 0
+	for _, argument := range cliContext.Args() {
+		// Incase debug is provided as argument.
+		if argument == "debug" {
+			setDebug(true)
+		} else if next < len(values) {
+			*values[next] = argument
+			next++
+		}
+	}
+}
+
 // StorjMongoDB created the CLI tool
 func main() {
 
@@ -0,0 +1,171 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc64"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Output formats of MongoReader.
+const (
+	// FormatBSON is the framed stream described by StreamFormatVersion.
+	FormatBSON = "bson"
+	// FormatArchive is the format written by `mongodump --archive`,
+	// which can be restored with `mongorestore --archive`.
+	FormatArchive = "archive"
+)
+
+// archiveMagicNumber starts every mongodump archive.
+const archiveMagicNumber uint32 = 0x8199e26d
+
+// archiveFormatVersion is the version of the mongodump archive format written.
+const archiveFormatVersion = "0.1"
+
+// archiveTerminator ends the prelude and every namespace block of an archive.
+var archiveTerminator = []byte{0xff, 0xff, 0xff, 0xff}
+
+// archiveCRCTable is the table for the checksum of each namespace's documents.
+var archiveCRCTable = crc64.MakeTable(crc64.ECMA)
+
+// archiveHeader is the first document of an archive, following its magic number.
+type archiveHeader struct {
+	ConcurrentCollections int32  `bson:"concurrent_collections"`
+	FormatVersion         string `bson:"version"`
+	ServerVersion         string `bson:"server_version"`
+	ToolVersion           string `bson:"tool_version"`
+}
+
+// archiveCollectionMetadata describes a collection in the prelude of an archive.
+type archiveCollectionMetadata struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	// Metadata is the collection's metadata file of mongodump, in extended JSON.
+	Metadata string `bson:"metadata"`
+	Type     string `bson:"type"`
+}
+
+// archiveNamespaceHeader precedes a block of a collection's documents,
+// or marks the end of the collection when EOF is set.
+type archiveNamespaceHeader struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	EOF        bool   `bson:"EOF"`
+	CRC        int64  `bson:"CRC"`
+}
+
+// archiveMetadata is the content of the collection's metadata in the prelude.
+type archiveMetadata struct {
+	Options        bson.Raw   `bson:"options"`
+	Indexes        []bson.Raw `bson:"indexes"`
+	CollectionName string     `bson:"collectionName"`
+	Type           string     `bson:"type"`
+}
+
+// archivePrelude returns the magic number, header and prelude of an archive
+// containing the given collections.
+func (mongoReader *MongoReader) archivePrelude(ctx context.Context, collectionNames []string) ([]byte, error) {
+	var prelude bytes.Buffer
+
+	magicNumber := make([]byte, 4)
+	binary.LittleEndian.PutUint32(magicNumber, archiveMagicNumber)
+	prelude.Write(magicNumber)
+
+	serverVersion, err := mongoReader.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headerBSON, err := bson.Marshal(archiveHeader{
+		ConcurrentCollections: 1,
+		FormatVersion:         archiveFormatVersion,
+		ServerVersion:         serverVersion,
+		ToolVersion:           "storj-mongodb",
+	})
+	if err != nil {
+		return nil, err
+	}
+	prelude.Write(headerBSON)
+
+	for _, collectionName := range collectionNames {
+		specification := mongoReader.collectionSpecs[collectionName]
+		indexes := []bson.Raw{}
+		if !specification.IsView() {
+			listedIndexes, err := listIndexes(ctx, mongoReader.database.Collection(collectionName))
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, listedIndexes...)
+		}
+		options := specification.Options
+		if len(options) == 0 {
+			options, _ = bson.Marshal(bson.D{})
+		}
+		metadataJSON, err := bson.MarshalExtJSON(archiveMetadata{
+			Options:        options,
+			Indexes:        indexes,
+			CollectionName: collectionName,
+			Type:           specification.Type,
+		}, true, false)
+		if err != nil {
+			return nil, err
+		}
+		metadataBSON, err := bson.Marshal(archiveCollectionMetadata{
+			Database:   mongoReader.DatabaseName,
+			Collection: collectionName,
+			Metadata:   string(metadataJSON),
+			Type:       specification.Type,
+		})
+		if err != nil {
+			return nil, err
+		}
+		prelude.Write(metadataBSON)
+	}
+	prelude.Write(archiveTerminator)
+
+	return prelude.Bytes(), nil
+}
+
+// archiveNamespaceStart returns the header of the block of a collection's documents.
+func (mongoReader *MongoReader) archiveNamespaceStart(collectionName string) ([]byte, error) {
+	return bson.Marshal(archiveNamespaceHeader{Database: mongoReader.DatabaseName, Collection: collectionName})
+}
+
+// archiveNamespaceEnd returns the end of the block of a collection's documents,
+// followed by the block marking the end of the collection with the checksum
+// of its documents.
+func (mongoReader *MongoReader) archiveNamespaceEnd(collectionName string, crc uint64) ([]byte, error) {
+	eofBSON, err := bson.Marshal(archiveNamespaceHeader{
+		Database:   mongoReader.DatabaseName,
+		Collection: collectionName,
+		EOF:        true,
+		CRC:        int64(crc),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var end bytes.Buffer
+	end.Write(archiveTerminator)
+	end.Write(eofBSON)
+	end.Write(archiveTerminator)
+	return end.Bytes(), nil
+}
+
+// isArchive tells whether the data starts with the magic number of an archive.
+func isArchive(data []byte) bool {
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data) == archiveMagicNumber
+}
+
+// ServerVersion returns the version of the connected MongoDB server.
+func (mongoReader *MongoReader) ServerVersion(ctx context.Context) (string, error) {
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	err := mongoReader.database.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo)
+	return buildInfo.Version, err
+}
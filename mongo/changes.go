@@ -0,0 +1,282 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeStreamAwaitTime bounds how long the server waits for change events before answering.
+const changeStreamAwaitTime = time.Second
+
+// invalidateOperation ends a change stream, e.g. once its database is dropped.
+const invalidateOperation = "invalidate"
+
+// ErrChangeStreamInvalidated is returned when the watched database is dropped or renamed.
+var ErrChangeStreamInvalidated = errors.New("change stream invalidated")
+
+// ChangeEvent is the part of a change event telling what it is about.
+type ChangeEvent struct {
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Namespace     struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+}
+
+// ChangeBatch holds change events, as concatenated raw BSON documents.
+type ChangeBatch struct {
+	Data   []byte
+	Events int64
+	// First and Last are the cluster times of the first and last events.
+	First primitive.Timestamp
+	Last  primitive.Timestamp
+	// ResumeToken resumes watching after the batch.
+	ResumeToken bson.Raw
+}
+
+// ChangeStream watches the changes of a database.
+type ChangeStream struct {
+	DatabaseName string
+	// Filter selects the collections whose changes are read.
+	Filter CollectionFilter
+	stream *mongo.ChangeStream
+}
+
+// WatchChanges opens a change stream on the database, starting after the
+// resume token, if given, or else from now. It needs a replica set.
+func (mongoReader *MongoReader) WatchChanges(ctx context.Context, resumeToken bson.Raw) (*ChangeStream, error) {
+	streamOptions := options.ChangeStream().SetMaxAwaitTime(changeStreamAwaitTime)
+	if len(resumeToken) > 0 {
+		streamOptions.SetResumeAfter(resumeToken)
+	}
+
+	stream, err := mongoReader.database.Watch(ctx, mongo.Pipeline{}, streamOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeStream{DatabaseName: mongoReader.DatabaseName, Filter: mongoReader.Filter, stream: stream}, nil
+}
+
+// NextBatch reads change events until maxEvents are read or maxWait has elapsed.
+// On errors, the events read so far are returned along with the error.
+func (changeStream *ChangeStream) NextBatch(ctx context.Context, maxEvents int64, maxWait time.Duration) (*ChangeBatch, error) {
+	batch := &ChangeBatch{}
+	deadline := time.Now().Add(maxWait)
+
+	var err error
+	for batch.Events < maxEvents && time.Now().Before(deadline) {
+		if !changeStream.stream.TryNext(ctx) {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+			if err = changeStream.stream.Err(); err != nil {
+				break
+			}
+			continue
+		}
+
+		var event ChangeEvent
+		if err = changeStream.stream.Decode(&event); err != nil {
+			break
+		}
+		if event.OperationType == invalidateOperation {
+			err = ErrChangeStreamInvalidated
+			break
+		}
+		if event.Namespace.Collection != "" && !changeStream.Filter.Matches(event.Namespace.Collection) {
+			continue
+		}
+
+		if batch.Events == 0 {
+			batch.First = event.ClusterTime
+		}
+		batch.Last = event.ClusterTime
+		batch.Data = append(batch.Data, changeStream.stream.Current...)
+		batch.Events++
+	}
+
+	// Copy the resume token, as the stream reuses its buffer.
+	batch.ResumeToken = append(bson.Raw(nil), changeStream.stream.ResumeToken()...)
+	return batch, err
+}
+
+// Close closes the change stream.
+func (changeStream *ChangeStream) Close(ctx context.Context) error {
+	return changeStream.stream.Close(ctx)
+}
+
+// ChangeWriter returns a writer applying the change events recorded on the
+// sourceDatabase after the cluster time after, up to until included, to the
+// database of the writer, for the collections selected by its filter.
+func (mongoWriter *MongoWriter) ChangeWriter(sourceDatabase string, after primitive.Timestamp, until primitive.Timestamp) *ChangeWriter {
+	return &ChangeWriter{
+		DatabaseName:   mongoWriter.DatabaseName,
+		SourceDatabase: sourceDatabase,
+		After:          after,
+		Until:          until,
+		Filter:         mongoWriter.Filter,
+		database:       mongoWriter.database,
+	}
+}
+
+// ChangeWriter implements an io.Writer interface, applying the raw BSON
+// change events written to it, as uploaded by the watch command.
+type ChangeWriter struct {
+	DatabaseName string
+	// SourceDatabase is the database the events were recorded on.
+	SourceDatabase string
+	// After and Until bound the cluster times of the events applied.
+	After      primitive.Timestamp
+	Until      primitive.Timestamp
+	Filter     CollectionFilter
+	EventCount int64
+	database   *mongo.Database
+	documentSplitter
+}
+
+// changeEventDocument is a change event with the changes it describes.
+type changeEventDocument struct {
+	ChangeEvent       `bson:",inline"`
+	DocumentKey       bson.Raw          `bson:"documentKey"`
+	FullDocument      bson.Raw          `bson:"fullDocument"`
+	UpdateDescription updateDescription `bson:"updateDescription"`
+	To                struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"to"`
+}
+
+// updateDescription describes the fields changed by an update event.
+type updateDescription struct {
+	UpdatedFields bson.Raw `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+	// TruncatedArrays are the arrays shortened by the update,
+	// reported apart by MongoDB 5.0 and later.
+	TruncatedArrays []struct {
+		Field   string `bson:"field"`
+		NewSize int    `bson:"newSize"`
+	} `bson:"truncatedArrays"`
+}
+
+// updates returns the updates making the changes of an update event, in order.
+// The arrays are truncated first, by an update of their own, as the updated
+// fields may be elements of them.
+func (description updateDescription) updates() []bson.D {
+	var updates []bson.D
+	if len(description.TruncatedArrays) > 0 {
+		truncated := bson.D{}
+		for _, array := range description.TruncatedArrays {
+			truncated = append(truncated, bson.E{Key: array.Field, Value: bson.D{
+				{Key: "$each", Value: bson.A{}},
+				{Key: "$slice", Value: array.NewSize},
+			}})
+		}
+		updates = append(updates, bson.D{{Key: "$push", Value: truncated}})
+	}
+
+	update := bson.D{}
+	// An empty $set is refused by MongoDB.
+	if updatedFields, _ := description.UpdatedFields.Elements(); len(updatedFields) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: description.UpdatedFields})
+	}
+	if len(description.RemovedFields) > 0 {
+		removed := bson.D{}
+		for _, field := range description.RemovedFields {
+			removed = append(removed, bson.E{Key: field, Value: ""})
+		}
+		update = append(update, bson.E{Key: "$unset", Value: removed})
+	}
+	if len(update) > 0 {
+		updates = append(updates, update)
+	}
+	return updates
+}
+
+// Write splits the written data into change events and applies them,
+// as soon as they are complete.
+func (changeWriter *ChangeWriter) Write(data []byte) (int, error) {
+	return changeWriter.write(data, changeWriter.writeEvent)
+}
+
+// Close fails if the stream ended in the middle of an event.
+func (changeWriter *ChangeWriter) Close() error {
+	return changeWriter.close()
+}
+
+// writeEvent applies a change event, unless it is out of the cluster times of
+// the writer, of another database, or about a collection left out by the filter.
+func (changeWriter *ChangeWriter) writeEvent(rawEventBSON bson.Raw) error {
+	var event changeEventDocument
+	if err := bson.Unmarshal(rawEventBSON, &event); err != nil {
+		return err
+	}
+
+	if !timestampAfter(event.ClusterTime, changeWriter.After) || timestampAfter(event.ClusterTime, changeWriter.Until) {
+		return nil
+	}
+	collectionName := event.Namespace.Collection
+	if event.Namespace.Database != changeWriter.SourceDatabase ||
+		(collectionName != "" && !changeWriter.Filter.Matches(collectionName)) {
+		return nil
+	}
+
+	if err := changeWriter.applyEvent(context.TODO(), event); err != nil {
+		log.Printf("Failed to apply %s change event of %s collection: %s\n", event.OperationType, collectionName, err)
+		return err
+	}
+	changeWriter.EventCount++
+	return nil
+}
+
+// applyEvent makes the change of the event to the writer's database.
+func (changeWriter *ChangeWriter) applyEvent(ctx context.Context, event changeEventDocument) error {
+	collection := changeWriter.database.Collection(event.Namespace.Collection)
+
+	switch event.OperationType {
+	case "insert", "replace":
+		_, err := collection.ReplaceOne(ctx, event.DocumentKey, event.FullDocument, options.Replace().SetUpsert(true))
+		return err
+	case "update":
+		for _, update := range event.UpdateDescription.updates() {
+			if _, err := collection.UpdateOne(ctx, event.DocumentKey, update); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "delete":
+		_, err := collection.DeleteOne(ctx, event.DocumentKey)
+		return err
+	case "drop":
+		return collection.Drop(ctx)
+	case "rename":
+		if event.To.Database != changeWriter.SourceDatabase {
+			// Renamed out of the database, as if it were dropped.
+			return collection.Drop(ctx)
+		}
+		return changeWriter.database.Client().Database("admin").RunCommand(ctx, bson.D{
+			{Key: "renameCollection", Value: changeWriter.DatabaseName + "." + event.Namespace.Collection},
+			{Key: "to", Value: changeWriter.DatabaseName + "." + event.To.Collection},
+			{Key: "dropTarget", Value: true},
+		}).Err()
+	case "dropDatabase":
+		return changeWriter.database.Drop(ctx)
+	}
+	// Other events, e.g. of indexes created by newer servers, are not replayed.
+	return nil
+}
+
+// timestampAfter tells whether the timestamp t is later than u.
+func timestampAfter(t primitive.Timestamp, u primitive.Timestamp) bool {
+	return t.T > u.T || (t.T == u.T && t.I > u.I)
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUpdateDescriptionUpdates(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		description bson.D
+		updates     []bson.D
+	}{
+		{
+			name: "updated and removed fields",
+			description: bson.D{
+				{Key: "updatedFields", Value: bson.D{{Key: "name", Value: "ada"}}},
+				{Key: "removedFields", Value: bson.A{"age"}},
+			},
+			updates: []bson.D{{
+				{Key: "$set", Value: bson.D{{Key: "name", Value: "ada"}}},
+				{Key: "$unset", Value: bson.D{{Key: "age", Value: ""}}},
+			}},
+		},
+		{
+			name: "truncated arrays",
+			description: bson.D{
+				{Key: "updatedFields", Value: bson.D{{Key: "tags.1", Value: "b"}}},
+				{Key: "removedFields", Value: bson.A{}},
+				{Key: "truncatedArrays", Value: bson.A{
+					bson.D{{Key: "field", Value: "tags"}, {Key: "newSize", Value: int32(2)}},
+					bson.D{{Key: "field", Value: "address.lines"}, {Key: "newSize", Value: int32(0)}},
+				}},
+			},
+			updates: []bson.D{
+				{{Key: "$push", Value: bson.D{
+					{Key: "tags", Value: bson.D{{Key: "$each", Value: bson.A{}}, {Key: "$slice", Value: 2}}},
+					{Key: "address.lines", Value: bson.D{{Key: "$each", Value: bson.A{}}, {Key: "$slice", Value: 0}}},
+				}}},
+				{{Key: "$set", Value: bson.D{{Key: "tags.1", Value: "b"}}}},
+			},
+		},
+		{
+			name:        "no change",
+			description: bson.D{{Key: "updatedFields", Value: bson.D{}}, {Key: "removedFields", Value: bson.A{}}},
+		},
+	} {
+		rawEventBSON, err := bson.Marshal(bson.D{
+			{Key: "operationType", Value: "update"},
+			{Key: "ns", Value: bson.D{{Key: "db", Value: "testdb"}, {Key: "coll", Value: "users"}}},
+			{Key: "documentKey", Value: bson.D{{Key: "_id", Value: 1}}},
+			{Key: "updateDescription", Value: test.description},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event changeEventDocument
+		if err := bson.Unmarshal(rawEventBSON, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		updates := event.UpdateDescription.updates()
+		if len(updates) != len(test.updates) {
+			t.Errorf("%s: got %d updates %v, want %v", test.name, len(updates), updates, test.updates)
+			continue
+		}
+		for i, update := range updates {
+			got, err := bson.MarshalExtJSON(update, false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := bson.MarshalExtJSON(test.updates[i], false, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s: update %d is %s, want %s", test.name, i, got, want)
+			}
+		}
+	}
+}
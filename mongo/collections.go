@@ -0,0 +1,96 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Types of the collections listed by MongoDB.
+const (
+	collectionType = "collection"
+	viewType       = "view"
+)
+
+// namespaceExistsCode is the error code of MongoDB for creating an existing collection.
+const namespaceExistsCode = 48
+
+// CollectionSpecification describes a collection, as listed by the listCollections command.
+// Its options hold e.g. capped sizes, validators, collation,
+// time-series options, or the source and pipeline of a view.
+type CollectionSpecification struct {
+	Name    string   `bson:"name"`
+	Type    string   `bson:"type"`
+	Options bson.Raw `bson:"options"`
+}
+
+// IsView tells whether the collection is a view, whose documents
+// are computed from another collection rather than stored.
+func (specification CollectionSpecification) IsView() bool {
+	return specification.Type == viewType
+}
+
+// listCollections returns the specifications of the database's collections
+// matching the filter. System collections, which MongoDB maintains itself,
+// are left out as mongodump does, except for stored JavaScript functions.
+func listCollections(ctx context.Context, database *mongo.Database, filter interface{}) ([]CollectionSpecification, error) {
+	cursor, err := database.ListCollections(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var specifications []CollectionSpecification
+	for cursor.Next(ctx) {
+		var specification CollectionSpecification
+		if err := cursor.Decode(&specification); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(specification.Name, "system.") && specification.Name != "system.js" {
+			continue
+		}
+		if specification.Type == "" {
+			// Servers before MongoDB 3.4 only list collections.
+			specification.Type = collectionType
+		}
+		// Copy the options, as the cursor reuses its buffer.
+		specification.Options = append(bson.Raw(nil), specification.Options...)
+		specifications = append(specifications, specification)
+	}
+	return specifications, cursor.Err()
+}
+
+// createCollection creates the collection, or view, of the header with its options.
+// An existing collection is kept as it is.
+func createCollection(ctx context.Context, database *mongo.Database, header CollectionHeader) error {
+	command := bson.D{{Key: "create", Value: header.Name}}
+	if len(header.Options) > 0 {
+		elements, err := header.Options.Elements()
+		if err != nil {
+			return err
+		}
+		for _, element := range elements {
+			command = append(command, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+	}
+
+	err := database.RunCommand(ctx, command).Err()
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) && commandError.Code == namespaceExistsCode {
+		fmt.Printf("Collection %s exists already, keeping its options\n", header.Name)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to create %s collection: %s\n", header.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CollectionFilter selects collections by name. Its patterns are exact names,
+// glob patterns, e.g. "orders.*", or regular expressions between slashes,
+// e.g. "/^log_[0-9]+$/".
+type CollectionFilter struct {
+	// Include selects only the collections matching any of its patterns, when not empty.
+	Include []string `json:"includeCollections"`
+	// Exclude leaves out the collections matching any of its patterns.
+	Exclude []string `json:"excludeCollections"`
+}
+
+// Matches tells whether the collection is selected by the filter.
+func (filter CollectionFilter) Matches(collectionName string) bool {
+	if len(filter.Include) > 0 && !matchesAny(filter.Include, collectionName) {
+		return false
+	}
+	return !matchesAny(filter.Exclude, collectionName)
+}
+
+// Check fails for malformed patterns.
+func (filter CollectionFilter) Check() error {
+	for _, pattern := range append(append([]string(nil), filter.Include...), filter.Exclude...) {
+		if _, err := matchPattern(pattern, ""); err != nil {
+			return fmt.Errorf("invalid collection pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesAny tells whether the collection matches any of the patterns.
+// Malformed patterns match nothing.
+func matchesAny(patterns []string, collectionName string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := matchPattern(pattern, collectionName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern tells whether the collection matches the pattern.
+func matchPattern(pattern string, collectionName string) (bool, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.MatchString(pattern[1:len(pattern)-1], collectionName)
+	}
+	return path.Match(pattern, collectionName)
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import "testing"
+
+func TestCollectionFilterMatches(t *testing.T) {
+	for _, test := range []struct {
+		filter         CollectionFilter
+		collectionName string
+		matches        bool
+	}{
+		{CollectionFilter{}, "users", true},
+		{CollectionFilter{Include: []string{"users"}}, "users", true},
+		{CollectionFilter{Include: []string{"users"}}, "orders", false},
+		{CollectionFilter{Include: []string{"orders.*"}}, "orders.2020", true},
+		{CollectionFilter{Include: []string{"orders.*"}}, "orders", false},
+		{CollectionFilter{Include: []string{"/^orders_[0-9]+$/"}}, "orders_42", true},
+		{CollectionFilter{Include: []string{"/^orders_[0-9]+$/"}}, "orders_x", false},
+		{CollectionFilter{Exclude: []string{"log_*"}}, "log_access", false},
+		{CollectionFilter{Exclude: []string{"log_*"}}, "users", true},
+		{CollectionFilter{Include: []string{"*"}, Exclude: []string{"/log/"}}, "access_log_2020", false},
+		{CollectionFilter{Include: []string{"users", "orders"}, Exclude: []string{"orders"}}, "orders", false},
+		{CollectionFilter{Include: []string{"["}}, "users", false},
+	} {
+		if matches := test.filter.Matches(test.collectionName); matches != test.matches {
+			t.Errorf("%+v matches %q: got %v, want %v", test.filter, test.collectionName, matches, test.matches)
+		}
+	}
+}
+
+func TestCollectionFilterCheck(t *testing.T) {
+	for _, test := range []struct {
+		filter CollectionFilter
+		valid  bool
+	}{
+		{CollectionFilter{}, true},
+		{CollectionFilter{Include: []string{"users", "orders.*"}, Exclude: []string{"/^log_/"}}, true},
+		{CollectionFilter{Include: []string{"["}}, false},
+		{CollectionFilter{Exclude: []string{"/(/"}}, false},
+	} {
+		if err := test.filter.Check(); (err == nil) != test.valid {
+			t.Errorf("%+v: got %v, want valid %v", test.filter, err, test.valid)
+		}
+	}
+}
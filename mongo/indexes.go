@@ -0,0 +1,75 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// idIndexName is the name of the index MongoDB creates on _id for every collection.
+const idIndexName = "_id_"
+
+// listIndexes returns the specifications of the collection's indexes,
+// as reported by the listIndexes command.
+func listIndexes(ctx context.Context, collection *mongo.Collection) ([]bson.Raw, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []bson.Raw
+	for cursor.Next(ctx) {
+		// Copy the specification, as the cursor reuses its buffer.
+		index := make(bson.Raw, len(cursor.Current))
+		copy(index, cursor.Current)
+		indexes = append(indexes, index)
+	}
+	return indexes, cursor.Err()
+}
+
+// createIndexes creates the indexes from their specifications on the collection,
+// except the index on _id that exists already.
+func createIndexes(ctx context.Context, collection *mongo.Collection, indexes []bson.Raw) error {
+	var specifications bson.A
+	for _, index := range indexes {
+		specification := bson.D{}
+		elements, err := index.Elements()
+		if err != nil {
+			return err
+		}
+		for _, element := range elements {
+			// The namespace is given by the command,
+			// and is not accepted as part of the index by recent servers.
+			if element.Key() == "ns" {
+				continue
+			}
+			specification = append(specification, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+		if name, ok := index.Lookup("name").StringValueOK(); ok && name == idIndexName {
+			continue
+		}
+		specifications = append(specifications, specification)
+	}
+	if len(specifications) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Creating %d indexes on %s collection...\n", len(specifications), collection.Name())
+
+	command := bson.D{
+		{Key: "createIndexes", Value: collection.Name()},
+		{Key: "indexes", Value: specifications},
+	}
+	if err := collection.Database().RunCommand(ctx, command).Err(); err != nil {
+		log.Printf("Failed to create indexes on %s collection: %s\n", collection.Name(), err)
+		return err
+	}
+	return nil
+}
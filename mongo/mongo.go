@@ -7,15 +7,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
 // DEBUG allows more detailed working to be exposed through the terminal.
@@ -23,143 +28,441 @@ var DEBUG = false
 
 // ConfigMongoDB defines the variables and types.
 type ConfigMongoDB struct {
+	// URI is a MongoDB connection string, e.g. a mongodb+srv:// one of Atlas.
+	// When set, it is used instead of the hostname, port, username and password.
+	URI        string `json:"uri"`
 	Hostname   string `json:"hostname"`
 	Portnumber string `json:"port"`
 	Username   string `json:"username"`
 	Password   string `json:"password"`
 	Database   string `json:"database"`
+	// Databases are backed up instead of the database, each under its own path.
+	Databases []string `json:"databases"`
+	// AllDatabases backs up every database but the admin, local and config ones.
+	AllDatabases bool `json:"allDatabases"`
+	// CollectionFilter selects the collections to be backed up and restored.
+	CollectionFilter
+	// Queries select the documents to be backed up of the collections they are keyed by.
+	Queries map[string]CollectionQuery `json:"queries"`
+	// TLS connects over TLS. It is implied by the other TLS settings,
+	// and by MONGODB-X509 authentication.
+	TLS bool `json:"tls"`
+	// TLSCAFile is a PEM file of the certificate authorities trusted to sign the server's certificate,
+	// instead of the system ones.
+	TLSCAFile string `json:"tlsCAFile"`
+	// TLSCertificateFile is a PEM file of the client certificate,
+	// along with its private key unless TLSPrivateKeyFile is set.
+	TLSCertificateFile string `json:"tlsCertificateFile"`
+	TLSPrivateKeyFile  string `json:"tlsPrivateKeyFile"`
+	// TLSInsecureSkipVerify accepts any server certificate, for test set-ups only.
+	TLSInsecureSkipVerify bool `json:"tlsInsecureSkipVerify"`
+	// AuthMechanism is e.g. "SCRAM-SHA-256", or "MONGODB-X509" to authenticate
+	// with the client certificate.
+	AuthMechanism string `json:"authMechanism"`
+}
+
+// CollectionStats counts the documents read from a collection.
+type CollectionStats struct {
+	Name    string
+	Type    string
+	Options bson.Raw
+	// Filter and Projection are the ones of the collection's query, if any.
+	Filter     bson.Raw
+	Projection bson.Raw
+	Documents  int64
+	Bytes      int64
 }
 
 // MongoReader implements an io.Reader interface
 type MongoReader struct {
-	DatabaseName      string
-	database          *mongo.Database
-	collectionNames   []string
-	lastDocumentIndex int
+	DatabaseName string
+	// CollectionName restricts the reader to a single collection, when set.
+	CollectionName string
+	// Format is the output format, FormatBSON when empty.
+	Format string
+	// Filter selects the collections to be read.
+	Filter CollectionFilter
+	// Queries select the documents to be read of the collections they are keyed by.
+	Queries map[string]CollectionQuery
+	// SnapshotRead reads all collections as of the same cluster time, AtClusterTime,
+	// which is the time of the first read unless it is set. It needs a replica set,
+	// or a sharded cluster, of MongoDB 5.0 or later.
+	SnapshotRead    bool
+	AtClusterTime   primitive.Timestamp
+	database        *mongo.Database
+	collectionNames []string
+	collectionSpecs map[string]CollectionSpecification
+	// cursor iterates over the documents of the current collection, by _id.
+	cursor *mongo.Cursor
+	// lastID is the _id of the last document read from the current collection,
+	// from which a failed cursor is resumed.
+	lastID            bson.RawValue
+	streamStarted     bool
+	collectionStarted bool
+	collectionRead    bool
+	collectionCRC     hash.Hash64
+	stats             []CollectionStats
+	pendingReader
 }
 
 // Read reads and copies as many collections' documents raw BSON data into the
-// buffer, as per the capacity of the buffer
+// buffer, as per the length of the buffer.
+// The data is framed as described by StreamFormatVersion,
+// or as a mongodump archive when Format is FormatArchive.
+// A document that does not fit into the buffer is copied partially,
+// and its remaining bytes are copied by the following calls.
 func (mongoReader *MongoReader) Read(buf []byte) (int, error) { // buf represents the byte array, where data is to be copied
 	// It returns number of bytes (int) that are copied
 	// and any error, if occurred.
 	// At the end of complete reading, io.EOF is sent as part of the error.
-	var err error
 	ctx := context.TODO()
-	bufferCapacity := cap(buf)
-	filterBSON := bson.M{}
-	//
-	if mongoReader.collectionNames == nil {
-		fmt.Println("Reading ALL collections from the MongoDB database...")
 
-		// Retrieve ALL collections in the database.
-		mongoReader.collectionNames, err = mongoReader.database.ListCollectionNames(ctx, filterBSON)
-		if err != nil {
-			log.Printf("Failed to retrieve collection names: %s\n", err)
-			return 0, err
-		}
-	} else {
-		fmt.Printf("Reading from MongoDB collection %s...\n", mongoReader.collectionNames[0])
+	if mongoReader.Format != "" && mongoReader.Format != FormatBSON && mongoReader.Format != FormatArchive {
+		return 0, fmt.Errorf("unknown output format %q", mongoReader.Format)
 	}
 
+	return mongoReader.read(buf, func(size int) error {
+		return mongoReader.readNext(ctx, size)
+	})
+}
+
+// pendingReader copies the data read ahead from MongoDB into the buffers of
+// the Read calls. It is embedded by the readers of BSON streams.
+type pendingReader struct {
+	// pending holds the data that is read from MongoDB,
+	// but not yet copied to the caller.
+	pending []byte
+	// ended is set once all data has been read into pending.
+	ended bool
+}
+
+// read copies the pending data into the buffer, calling readMore to read
+// more data, as much as the buffer has room for, until the buffer is full
+// or all data has been copied.
+func (reader *pendingReader) read(buf []byte, readMore func(size int) error) (int, error) {
 	var numOfBytesRead = 0
+	for {
+		// Copy the data read earlier first.
+		copied := copy(buf[numOfBytesRead:], reader.pending)
+		reader.pending = reader.pending[copied:]
+		numOfBytesRead += copied
+
+		if numOfBytesRead == len(buf) {
+			return numOfBytesRead, nil
+		}
+		if reader.ended {
+			return numOfBytesRead, io.EOF
+		}
+
+		if err := readMore(len(buf) - numOfBytesRead); err != nil {
+			return numOfBytesRead, err
+		}
+	}
+}
 
-	// Go through ALL collections.
-	for ij := range mongoReader.collectionNames {
+// readNext reads the next part of the stream into the pending data,
+// i.e. the start of the stream, the start or end of a collection, or at
+// least size bytes of a collection's documents, if it has that many left.
+func (mongoReader *MongoReader) readNext(ctx context.Context, size int) error {
+	if !mongoReader.streamStarted {
+		return mongoReader.startStream(ctx)
+	}
+
+	if len(mongoReader.collectionNames) == 0 {
+		// All collections have been read and processed.
+		mongoReader.ended = true
+		return nil
+	}
+
+	collection := mongoReader.database.Collection(mongoReader.collectionNames[0])
+
+	if !mongoReader.collectionStarted {
 		if DEBUG {
-			fmt.Println("Collection: ", mongoReader.collectionNames[ij])
+			fmt.Println("Collection: ", collection.Name())
 			fmt.Println("-----------------")
 		}
+		fmt.Printf("Reading from MongoDB collection %s...\n", collection.Name())
 
-		collection := mongoReader.database.Collection(mongoReader.collectionNames[ij])
-		//
-		cursor, err := collection.Find(ctx, filterBSON)
-		//
+		// Precede the collection's documents with its header.
+		collectionStart, err := mongoReader.collectionStart(ctx, collection)
 		if err != nil {
-			log.Printf("Failed to retrieve data about %s collection: %s\n", mongoReader.collectionNames[ij], err)
-			return numOfBytesRead, err
+			log.Printf("Failed to start %s collection: %s\n", collection.Name(), err)
+			return err
 		}
-		//
-		defer cursor.Close(ctx)
-
-		var documentCount = 0
-		var lastIndex = 0
-		// Retrieve each document of the selected collection.
-		for cursor.Next(ctx) {
-			// Start reading from the last document that was under process.
-			if documentCount >= mongoReader.lastDocumentIndex {
-				// Convert JSON to BSON.
-				//
-				rawDocumentBSON, _ := bson.Marshal(cursor.Current)
-
-				documentSize := len(rawDocumentBSON)
-
-				// Ensure required space is available in buf.
-				if (numOfBytesRead + documentSize) < bufferCapacity {
-
-					lastIndex = numOfBytesRead + len(rawDocumentBSON)
-					copy(buf[numOfBytesRead:lastIndex], rawDocumentBSON)
-
-					//
-					numOfBytesRead += documentSize
-				} else {
-					if DEBUG {
-						log.Printf("Buffer full with %d bytes of '%s' collection's data from documents #%d to #%d!\nCaller must recall the Reader.\n", numOfBytesRead, mongoReader.collectionNames[ij], mongoReader.lastDocumentIndex, documentCount)
-					}
-					// Insufficient space in the buffer.
-					err = io.ErrShortBuffer
-
-					// Next time, start with the unprocessed collections.
-					mongoReader.collectionNames = mongoReader.collectionNames[ij:]
-					//
-					// Also, operate from the current document instance.
-					mongoReader.lastDocumentIndex = documentCount + 1
-
-					// More data still needs to be sent,
-					// hence, caller must recall the Reader.
-					return numOfBytesRead, err
-				}
-			}
-			//
-			documentCount++
+		mongoReader.collectionStarted = true
+		mongoReader.collectionCRC = crc64.New(archiveCRCTable)
+		specification := mongoReader.collectionSpecs[collection.Name()]
+		filter, projection, err := mongoReader.Queries[collection.Name()].documents()
+		if err != nil {
+			return err
 		}
+		mongoReader.stats = append(mongoReader.stats, CollectionStats{Name: specification.Name, Type: specification.Type, Options: specification.Options, Filter: filter, Projection: projection})
 
-		if DEBUG {
-			fmt.Printf("Retrieved %d bytes of '%s' collection's data from documents #%d to #%d", numOfBytesRead, mongoReader.collectionNames[ij], mongoReader.lastDocumentIndex, documentCount)
+		// Views have no documents of their own, their definition is in the header.
+		mongoReader.collectionRead = specification.IsView()
+
+		mongoReader.pending = append(mongoReader.pending, collectionStart...)
+		return nil
+	}
+
+	if !mongoReader.collectionRead {
+		return mongoReader.readDocuments(ctx, collection, size)
+	}
+
+	// Follow the collection's documents with its end.
+	collectionEnd, err := mongoReader.collectionEnd(collection.Name())
+	if err != nil {
+		return err
+	}
+	mongoReader.pending = append(mongoReader.pending, collectionEnd...)
+
+	log.Println("ALL documents of the collection are read!")
+
+	// Next time, start with the next collection.
+	mongoReader.collectionNames = mongoReader.collectionNames[1:]
+	mongoReader.lastID = bson.RawValue{}
+	mongoReader.collectionStarted = false
+	mongoReader.collectionRead = false
+	return nil
+}
+
+// startStream lists the collections to be read,
+// and reads the start of the stream into the pending data.
+func (mongoReader *MongoReader) startStream(ctx context.Context) error {
+	listFilterBSON := bson.M{}
+	if mongoReader.CollectionName != "" {
+		listFilterBSON = bson.M{"name": mongoReader.CollectionName}
+	} else {
+		fmt.Println("Reading ALL collections from the MongoDB database...")
+	}
+
+	// Retrieve ALL collections in the database, with their specifications.
+	specifications, err := listCollections(ctx, mongoReader.database, listFilterBSON)
+	if err != nil {
+		log.Printf("Failed to retrieve collections: %s\n", err)
+		return err
+	}
+
+	mongoReader.collectionNames = nil
+	mongoReader.collectionSpecs = make(map[string]CollectionSpecification)
+	for _, specification := range specifications {
+		if mongoReader.CollectionName == "" && !mongoReader.Filter.Matches(specification.Name) {
+			continue
 		}
+		mongoReader.collectionNames = append(mongoReader.collectionNames, specification.Name)
+		mongoReader.collectionSpecs[specification.Name] = specification
+	}
+
+	// Start the stream with its header.
+	streamStart, err := mongoReader.streamStart(ctx)
+	if err != nil {
+		return err
+	}
+	mongoReader.streamStarted = true
+
+	mongoReader.pending = append(mongoReader.pending, streamStart...)
+	return nil
+}
+
+// readDocuments reads documents of the collection into the pending data,
+// until there are size bytes pending or the collection has been read.
+// The cursor is kept open across calls. Should it fail, the next call
+// reopens it after the last document read.
+func (mongoReader *MongoReader) readDocuments(ctx context.Context, collection *mongo.Collection, size int) error {
+	if mongoReader.cursor == nil {
+		cursor, err := mongoReader.openCursor(ctx, collection)
+		if err != nil {
+			log.Printf("Failed to retrieve data about %s collection: %s\n", collection.Name(), err)
+			return err
+		}
+		mongoReader.cursor = cursor
+	}
+	cursor := mongoReader.cursor
+
+	// Retrieve each document of the selected collection.
+	for cursor.Next(ctx) {
+		rawDocumentBSON := cursor.Current
 
-		if err := cursor.Err(); err != nil {
+		mongoReader.pending = append(mongoReader.pending, rawDocumentBSON...)
+		mongoReader.collectionCRC.Write(rawDocumentBSON)
+		mongoReader.countDocument(len(rawDocumentBSON))
+		// Copy the _id, as the cursor reuses its buffer.
+		lastID := rawDocumentBSON.Lookup("_id")
+		lastID.Value = append([]byte(nil), lastID.Value...)
+		mongoReader.lastID = lastID
+
+		if len(mongoReader.pending) >= size {
 			if DEBUG {
-				fmt.Printf(" before error: %s.\n", err)
+				log.Printf("Read %d bytes of '%s' collection's data up to _id %s!\n", len(mongoReader.pending), collection.Name(), mongoReader.lastID)
 			}
-			// Unexpected error occurred while processing cursors.
-			// Next time, start with the unprocessed collections.
-			mongoReader.collectionNames = mongoReader.collectionNames[ij:]
-			//
-			// Also, operate from the current document instance.
-			mongoReader.lastDocumentIndex = documentCount + 1
-
-			// The caller needs to recall the Reader to fetch left-over data.
-			return numOfBytesRead, err
+			return nil
 		}
+	}
 
+	err := cursor.Err()
+	cursor.Close(ctx)
+	mongoReader.cursor = nil
+	if err != nil {
 		if DEBUG {
-			fmt.Printf(".\n")
+			fmt.Printf("Retrieved '%s' collection's data up to _id %s before error: %s.\n", collection.Name(), mongoReader.lastID, err)
 		}
+		// The caller needs to recall the Reader to fetch left-over data.
+		return err
+	}
+
+	mongoReader.collectionRead = true
+	return nil
+}
 
-		log.Println("ALL documents of the collection are read!")
+// openCursor opens a cursor over the documents of the collection sorted by _id,
+// starting after the last document read, if any.
+// The documents, and their fields, are selected by the collection's query.
+func (mongoReader *MongoReader) openCursor(ctx context.Context, collection *mongo.Collection) (*mongo.Cursor, error) {
+	queryFilter, projection, err := mongoReader.Queries[collection.Name()].documents()
+	if err != nil {
+		return nil, err
+	}
 
-		// All documents of the selected collection have been read.
-		if mongoReader.lastDocumentIndex > 0 {
-			// Reset the document index to be read from.
-			mongoReader.lastDocumentIndex = 0
+	var filter interface{} = bson.M{}
+	if queryFilter != nil {
+		filter = queryFilter
+	}
+	if mongoReader.lastID.Type != 0 {
+		resumeFilter := bson.M{"_id": bson.M{"$gt": mongoReader.lastID}}
+		if queryFilter != nil {
+			filter = bson.M{"$and": bson.A{queryFilter, resumeFilter}}
+		} else {
+			filter = resumeFilter
 		}
 	}
 
-	// All collections have been read and processed.
-	mongoReader.collectionNames = nil
+	if mongoReader.SnapshotRead {
+		return mongoReader.findSnapshot(ctx, collection, filter, projection)
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if projection != nil {
+		findOptions.SetProjection(projection)
+	}
+	return collection.Find(ctx, filter, findOptions)
+}
+
+// Stats returns the number of documents, and their bytes,
+// read so far from each collection.
+func (mongoReader *MongoReader) Stats() []CollectionStats {
+	return mongoReader.stats
+}
+
+// countDocument adds a document to the stats of the current collection.
+func (mongoReader *MongoReader) countDocument(documentSize int) {
+	current := &mongoReader.stats[len(mongoReader.stats)-1]
+	current.Documents++
+	current.Bytes += int64(documentSize)
+}
+
+// Disconnect closes the connection to the MongoDB instance,
+// which the readers of all its databases share.
+func (mongoReader *MongoReader) Disconnect(ctx context.Context) error {
+	return mongoReader.database.Client().Disconnect(ctx)
+}
 
-	return numOfBytesRead, io.EOF
+// CollectionReaders returns a reader for each collection in the database,
+// each producing a complete stream of that collection in the output format.
+func (mongoReader *MongoReader) CollectionReaders() ([]*MongoReader, error) {
+	specifications, err := listCollections(context.TODO(), mongoReader.database, bson.M{})
+	if err != nil {
+		log.Printf("Failed to retrieve collections: %s\n", err)
+		return nil, err
+	}
+
+	// Read all collections as of the same cluster time.
+	if mongoReader.SnapshotRead {
+		if _, err := mongoReader.snapshotReadConcern(context.TODO()); err != nil {
+			log.Printf("Failed to retrieve the cluster time: %s\n", err)
+			return nil, err
+		}
+	}
+
+	collectionReaders := make([]*MongoReader, 0, len(specifications))
+	for _, specification := range specifications {
+		if !mongoReader.Filter.Matches(specification.Name) {
+			continue
+		}
+		collectionReaders = append(collectionReaders, &MongoReader{
+			DatabaseName:   mongoReader.DatabaseName,
+			CollectionName: specification.Name,
+			Format:         mongoReader.Format,
+			Filter:         mongoReader.Filter,
+			Queries:        mongoReader.Queries,
+			SnapshotRead:   mongoReader.SnapshotRead,
+			AtClusterTime:  mongoReader.AtClusterTime,
+			database:       mongoReader.database,
+		})
+	}
+	return collectionReaders, nil
+}
+
+// streamStart returns the data starting the stream in the output format.
+func (mongoReader *MongoReader) streamStart(ctx context.Context) ([]byte, error) {
+	if mongoReader.Format == FormatArchive {
+		return mongoReader.archivePrelude(ctx, mongoReader.collectionNames)
+	}
+	return bson.Marshal(StreamHeader{Version: StreamFormatVersion, Database: mongoReader.DatabaseName})
+}
+
+// collectionStart returns the data preceding a collection's documents in the output format.
+func (mongoReader *MongoReader) collectionStart(ctx context.Context, collection *mongo.Collection) ([]byte, error) {
+	specification := mongoReader.collectionSpecs[collection.Name()]
+	if mongoReader.Format == FormatArchive {
+		if specification.IsView() {
+			// The prelude holds all there is about a view.
+			return nil, nil
+		}
+		return mongoReader.archiveNamespaceStart(collection.Name())
+	}
+
+	header := CollectionHeader{Name: collection.Name(), Type: specification.Type, Options: specification.Options}
+	if !specification.IsView() {
+		queryFilter, _, err := mongoReader.Queries[collection.Name()].documents()
+		if err != nil {
+			return nil, err
+		}
+		var filter interface{} = bson.M{}
+		if queryFilter != nil {
+			filter = queryFilter
+		}
+		var documentTotal int64
+		if mongoReader.SnapshotRead {
+			documentTotal, err = mongoReader.countSnapshot(ctx, collection, filter)
+		} else {
+			documentTotal, err = collection.CountDocuments(ctx, filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		header.Count = documentTotal
+		header.Indexes, err = listIndexes(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bson.Marshal(header)
+}
+
+// collectionEnd returns the data following a collection's documents in the output format.
+func (mongoReader *MongoReader) collectionEnd(collectionName string) ([]byte, error) {
+	if mongoReader.Format == FormatArchive && !mongoReader.collectionSpecs[collectionName].IsView() {
+		return mongoReader.archiveNamespaceEnd(collectionName, mongoReader.collectionCRC.Sum64())
+	}
+	return nil, nil
+}
+
+// FileExtension returns the extension of the objects in the output format.
+func (mongoReader *MongoReader) FileExtension() string {
+	if mongoReader.Format == FormatArchive {
+		return ".archive"
+	}
+	return ".bson"
 }
 
 // LoadMongoProperty reads and parses the JSON file.
@@ -177,13 +480,55 @@ func LoadMongoProperty(fullFileName string) (ConfigMongoDB, error) { // fullFile
 	jsonParser := json.NewDecoder(fileHandle)
 	jsonParser.Decode(&configMongoDB)
 
+	// The database defaults to the one of the connection string.
+	if configMongoDB.URI != "" && configMongoDB.Database == "" {
+		connectionString, err := connstring.Parse(configMongoDB.URI)
+		if err != nil {
+			return configMongoDB, err
+		}
+		configMongoDB.Database = connectionString.Database
+	}
+
+	if err := configMongoDB.CollectionFilter.Check(); err != nil {
+		return configMongoDB, err
+	}
+	for collectionName, query := range configMongoDB.Queries {
+		if err := query.Check(); err != nil {
+			return configMongoDB, fmt.Errorf("invalid query of %s collection: %v", collectionName, err)
+		}
+	}
+
 	// Display read information.
 	fmt.Println("Read MongoDB configuration from the ", fullFileName, " file")
+	if configMongoDB.URI != "" {
+		fmt.Println("URI \t\t", configMongoDB.URI)
+	}
 	fmt.Println("Hostname\t", configMongoDB.Hostname)
 	fmt.Println("Portnumber\t", configMongoDB.Portnumber)
 	fmt.Println("Username \t", configMongoDB.Username)
 	fmt.Println("Password \t", configMongoDB.Password)
 	fmt.Println("Database \t", configMongoDB.Database)
+	if len(configMongoDB.Databases) > 0 {
+		fmt.Println("Databases \t", configMongoDB.Databases)
+	}
+	if configMongoDB.AllDatabases {
+		fmt.Println("Databases \t", "ALL")
+	}
+	if len(configMongoDB.Include) > 0 {
+		fmt.Println("Include \t", configMongoDB.Include)
+	}
+	if len(configMongoDB.Exclude) > 0 {
+		fmt.Println("Exclude \t", configMongoDB.Exclude)
+	}
+	for collectionName, query := range configMongoDB.Queries {
+		fmt.Println("Query \t\t", collectionName, string(query.Filter), string(query.Projection))
+	}
+	if usesTLS(configMongoDB) {
+		fmt.Println("TLS \t\t", "CA file:", configMongoDB.TLSCAFile, "certificate file:", configMongoDB.TLSCertificateFile, "insecure:", configMongoDB.TLSInsecureSkipVerify)
+	}
+	if configMongoDB.AuthMechanism != "" {
+		fmt.Println("Mechanism \t", configMongoDB.AuthMechanism)
+	}
 
 	return configMongoDB, nil
 }
@@ -200,11 +545,103 @@ func ConnectToDB(fullFileName string) (*MongoReader, error) { // fullFileName fo
 		return nil, err
 	}
 
-	fmt.Println("Connecting to MongoDB...")
+	client, err := connectClient(configMongoDB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoReader{DatabaseName: configMongoDB.Database, Filter: configMongoDB.CollectionFilter, Queries: configMongoDB.Queries, database: client.Database(configMongoDB.Database)}, nil
+}
 
-	mongoURL := fmt.Sprintf("mongodb://%s:%s@%s:%s/%s?authSource="+configMongoDB.Database, configMongoDB.Username, configMongoDB.Password, configMongoDB.Hostname, configMongoDB.Portnumber, configMongoDB.Database)
+// ConnectToDatabases will connect to a MongoDB instance,
+// based on the read property from an external file.
+// It returns a reference to an io.Reader of each database to be backed up:
+// every database but the system ones if allDatabases is set,
+// else the listed databases, or else the configured database.
+func ConnectToDatabases(fullFileName string) ([]*MongoReader, error) { // fullFileName for fetching database credentials from given JSON filename.
+	// Read MongoDB instance's properties from an external file.
+	configMongoDB, err := LoadMongoProperty(fullFileName)
 	//
-	clientOptions := options.Client().ApplyURI(mongoURL)
+	if err != nil {
+		log.Printf("LoadMongoProperty: %s\n", err)
+		return nil, err
+	}
+
+	client, err := connectClient(configMongoDB)
+	if err != nil {
+		return nil, err
+	}
+
+	databaseNames := configMongoDB.Databases
+	if configMongoDB.AllDatabases {
+		databaseNames, err = listDatabases(context.TODO(), client)
+		if err != nil {
+			log.Printf("Failed to retrieve databases: %s\n", err)
+			return nil, err
+		}
+	} else if len(databaseNames) == 0 {
+		databaseNames = []string{configMongoDB.Database}
+	}
+
+	databaseReaders := make([]*MongoReader, 0, len(databaseNames))
+	for _, databaseName := range databaseNames {
+		databaseReaders = append(databaseReaders, &MongoReader{DatabaseName: databaseName, Filter: configMongoDB.CollectionFilter, Queries: configMongoDB.Queries, database: client.Database(databaseName)})
+	}
+	return databaseReaders, nil
+}
+
+// systemDatabases are left out when backing up all databases,
+// as MongoDB maintains them itself.
+var systemDatabases = map[string]bool{"admin": true, "local": true, "config": true}
+
+// listDatabases returns the names of the databases of the instance,
+// but the system ones.
+func listDatabases(ctx context.Context, client *mongo.Client) ([]string, error) {
+	names, err := client.ListDatabaseNames(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var databaseNames []string
+	for _, name := range names {
+		if !systemDatabases[name] {
+			databaseNames = append(databaseNames, name)
+		}
+	}
+	return databaseNames, nil
+}
+
+// ConnectToDBWriter will connect to a MongoDB instance,
+// based on the read property from an external file.
+// It returns a reference to an io.Writer that restores BSON documents
+// into the given collection of the configured database.
+func ConnectToDBWriter(fullFileName string, collectionName string) (*MongoWriter, error) { // fullFileName for fetching database credentials from given JSON filename.
+	// Read MongoDB instance's properties from an external file.
+	configMongoDB, err := LoadMongoProperty(fullFileName)
+	//
+	if err != nil {
+		log.Printf("LoadMongoProperty: %s\n", err)
+		return nil, err
+	}
+
+	client, err := connectClient(configMongoDB)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoWriter{DatabaseName: configMongoDB.Database, CollectionName: collectionName, Filter: configMongoDB.CollectionFilter, database: client.Database(configMongoDB.Database)}, nil
+}
+
+// connectClient connects to the MongoDB instance described by the
+// configuration and checks the connection.
+func connectClient(configMongoDB ConfigMongoDB) (*mongo.Client, error) {
+	fmt.Println("Connecting to MongoDB...")
+
+	clientOptions := options.Client().ApplyURI(mongoURI(configMongoDB))
+	if err := applySecurity(clientOptions, configMongoDB); err != nil {
+		log.Printf("Failed to configure the MongoDB connection: %s\n", err)
+		return nil, err
+	}
 	//
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	//
@@ -217,20 +654,42 @@ func ConnectToDB(fullFileName string) (*MongoReader, error) { // fullFileName fo
 	err = client.Ping(context.TODO(), nil)
 	//
 	if err != nil {
+		client.Disconnect(context.TODO())
 		return nil, err
 	}
 
 	// Inform about successful connection.
 	fmt.Println("Successfully connected to MongoDB!")
 
-	return &MongoReader{DatabaseName: configMongoDB.Database, database: client.Database(configMongoDB.Database)}, nil
+	return client, nil
+}
+
+// mongoURI returns the connection string of the configuration: its URI,
+// or else one built from its hostname, port, username and password.
+func mongoURI(configMongoDB ConfigMongoDB) string {
+	if configMongoDB.URI != "" {
+		return configMongoDB.URI
+	}
+
+	mongoURL := url.URL{
+		Scheme: "mongodb",
+		Host:   configMongoDB.Hostname + ":" + configMongoDB.Portnumber,
+		Path:   "/" + configMongoDB.Database,
+	}
+	if configMongoDB.Username != "" {
+		// The username and password are escaped, as they may contain e.g. '@' or ':'.
+		mongoURL.User = url.UserPassword(configMongoDB.Username, configMongoDB.Password)
+		// The driver refuses an authSource without username.
+		mongoURL.RawQuery = "authSource=" + url.QueryEscape(configMongoDB.Database)
+	}
+	return mongoURL.String()
 }
 
 // FetchData reads ALL collections' BSON data, and
 // returns them in appended format.
 func FetchData(databaseReader io.Reader) ([]byte, error) { // databaseReader is an io.Reader implementation that 'reads' desired data.
 	// Create a buffer of feasible size
-	rawDocumentBSON := make([]byte, 0, 35768)
+	rawDocumentBSON := make([]byte, 35768)
 	// Retrieve ALL collections in the database.
 	var allCollectionsDataBSON = []byte{}
 
@@ -238,18 +697,22 @@ func FetchData(databaseReader io.Reader) ([]byte, error) { // databaseReader is
 	var err error
 
 	// Read data using the given io.Reader.
-	for err = io.ErrShortBuffer; err == io.ErrShortBuffer; {
+	for err == nil {
 		numOfBytesRead, err = databaseReader.Read(rawDocumentBSON)
 		//
 		if numOfBytesRead > 0 {
 			// Append the BSON data to earlier one.
-			allCollectionsDataBSON = append(allCollectionsDataBSON[:], rawDocumentBSON...)
+			allCollectionsDataBSON = append(allCollectionsDataBSON, rawDocumentBSON[:numOfBytesRead]...)
 			//
 			if DEBUG {
-				fmt.Printf("Read %d bytes of data - Error: %s == %s => %t\n", numOfBytesRead, err, io.ErrShortBuffer, err == io.ErrShortBuffer)
+				fmt.Printf("Read %d bytes of data - Error: %v\n", numOfBytesRead, err)
 			}
 		}
 	}
+	if err != io.EOF {
+		return allCollectionsDataBSON, err
+	}
+	err = nil
 	//
 	if DEBUG {
 		// complete BSON data from ALL collections.
@@ -0,0 +1,387 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The oplog of a replica set member.
+const (
+	oplogDatabase   = "local"
+	oplogCollection = "oplog.rs"
+)
+
+// duplicateKeyCode is the error code of MongoDB for inserting an existing _id.
+const duplicateKeyCode = 11000
+
+// oplogEntryFields are the fields of an oplog entry kept when replaying it.
+// Others, e.g. the collection's UUID, are specific to the recorded deployment.
+var oplogEntryFields = map[string]bool{"op": true, "ns": true, "o": true, "o2": true}
+
+// ErrNoOplog is returned when the MongoDB instance has no oplog, i.e. is not a replica set member.
+var ErrNoOplog = errors.New("no oplog found, capturing it needs a replica set")
+
+// OplogTime returns the timestamp of the latest entry of the oplog.
+func (mongoReader *MongoReader) OplogTime(ctx context.Context) (primitive.Timestamp, error) {
+	var entry struct {
+		Timestamp primitive.Timestamp `bson:"ts"`
+	}
+	err := mongoReader.oplog().FindOne(ctx, bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "$natural", Value: -1}}).SetProjection(bson.M{"ts": 1}),
+	).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return entry.Timestamp, ErrNoOplog
+	}
+	return entry.Timestamp, err
+}
+
+// oplog returns the oplog collection of the connected MongoDB instance.
+func (mongoReader *MongoReader) oplog() *mongo.Collection {
+	return mongoReader.database.Client().Database(oplogDatabase).Collection(oplogCollection)
+}
+
+// OplogReader returns a reader of the oplog entries about the database
+// from start to end, both included, for the collections selected by its filter.
+func (mongoReader *MongoReader) OplogReader(start primitive.Timestamp, end primitive.Timestamp) *OplogReader {
+	return &OplogReader{
+		Start:        start,
+		End:          end,
+		DatabaseName: mongoReader.DatabaseName,
+		Filter:       mongoReader.Filter,
+		database:     mongoReader.database,
+		oplog:        mongoReader.oplog(),
+	}
+}
+
+// OplogReader implements an io.Reader interface, reading the raw BSON
+// oplog entries about a database, as `mongodump --oplog` records them.
+type OplogReader struct {
+	DatabaseName string
+	// Start and End are the timestamps of the first and last entries read.
+	Start primitive.Timestamp
+	End   primitive.Timestamp
+	// Filter leaves out the entries about the collections it does not select,
+	// and their operations from transactions.
+	Filter     CollectionFilter
+	EntryCount int64
+	database   *mongo.Database
+	oplog      *mongo.Collection
+	cursor     *mongo.Cursor
+	pendingReader
+}
+
+// Read reads and copies as many oplog entries into the buffer,
+// as per the length of the buffer.
+func (oplogReader *OplogReader) Read(buf []byte) (int, error) {
+	ctx := context.TODO()
+
+	return oplogReader.read(buf, func(size int) error {
+		return oplogReader.readEntries(ctx, size)
+	})
+}
+
+// readEntries reads entries into the pending data,
+// until there are size bytes pending or all entries have been read.
+func (oplogReader *OplogReader) readEntries(ctx context.Context, size int) error {
+	if oplogReader.cursor == nil {
+		namespaces := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(oplogReader.DatabaseName) + `\.`}
+		excluded, err := oplogReader.excludedNamespaces(ctx)
+		if err != nil {
+			log.Printf("Failed to retrieve collections: %s\n", err)
+			return err
+		}
+		cursor, err := oplogReader.oplog.Find(ctx, bson.M{
+			"ts": bson.M{"$gte": oplogReader.Start, "$lte": oplogReader.End},
+			"$or": bson.A{
+				bson.M{"ns": bson.M{"$regex": namespaces, "$nin": excluded}},
+				// Transactions are recorded as applyOps commands of the admin database.
+				bson.M{"ns": "admin.$cmd", "o.applyOps.ns": namespaces},
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to retrieve the oplog: %s\n", err)
+			return err
+		}
+		oplogReader.cursor = cursor
+	}
+
+	for oplogReader.cursor.Next(ctx) {
+		entry, err := oplogReader.filterEntry(oplogReader.cursor.Current)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+		oplogReader.pending = append(oplogReader.pending, entry...)
+		oplogReader.EntryCount++
+		if len(oplogReader.pending) >= size {
+			return nil
+		}
+	}
+
+	err := oplogReader.cursor.Err()
+	oplogReader.cursor.Close(ctx)
+	oplogReader.ended = true
+	return err
+}
+
+// excludedNamespaces returns the namespaces of the existing collections of the
+// database left out by the filter, so that the server does not send their entries.
+func (oplogReader *OplogReader) excludedNamespaces(ctx context.Context) (bson.A, error) {
+	specifications, err := listCollections(ctx, oplogReader.database, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	excluded := bson.A{}
+	for _, specification := range specifications {
+		if !oplogReader.Filter.Matches(specification.Name) {
+			excluded = append(excluded, oplogReader.DatabaseName+"."+specification.Name)
+		}
+	}
+	return excluded, nil
+}
+
+// filterEntry returns the raw oplog entry, without the operations of a transaction
+// about other databases or collections left out by the filter. It returns nil for
+// entries about such collections, e.g. created while the oplog was recorded.
+func (oplogReader *OplogReader) filterEntry(rawEntryBSON bson.Raw) (bson.Raw, error) {
+	var entry bson.D
+	if err := bson.Unmarshal(rawEntryBSON, &entry); err != nil {
+		return nil, err
+	}
+
+	operations, ok := transactionOperations(entry)
+	if !ok {
+		if !oplogReader.selects(entry) {
+			return nil, nil
+		}
+		return rawEntryBSON, nil
+	}
+
+	var selected bson.A
+	for _, operation := range operations {
+		if operationEntry, ok := operation.(bson.D); ok && oplogReader.selects(operationEntry) {
+			selected = append(selected, operationEntry)
+		}
+	}
+	switch len(selected) {
+	case 0:
+		return nil, nil
+	case len(operations):
+		return rawEntryBSON, nil
+	}
+
+	command := entryValue(entry, "o").(bson.D)
+	filteredCommand := make(bson.D, 0, len(command))
+	for _, element := range command {
+		if element.Key == "applyOps" {
+			element.Value = selected
+		}
+		filteredCommand = append(filteredCommand, element)
+	}
+	filtered := make(bson.D, 0, len(entry))
+	for _, element := range entry {
+		if element.Key == "o" {
+			element.Value = filteredCommand
+		}
+		filtered = append(filtered, element)
+	}
+	return bson.Marshal(filtered)
+}
+
+// selects tells whether an oplog entry is about the database of the reader,
+// and about a collection selected by its filter, or the database as a whole.
+func (oplogReader *OplogReader) selects(entry bson.D) bool {
+	namespace, _ := entryValue(entry, "ns").(string)
+	if !strings.HasPrefix(namespace, oplogReader.DatabaseName+".") {
+		return false
+	}
+	collectionName := entryCollection(oplogReader.DatabaseName, entry)
+	return collectionName == "" || oplogReader.Filter.Matches(collectionName)
+}
+
+// OplogWriter returns a writer replaying oplog entries recorded on the
+// sourceDatabase into the database of the writer, for the collections
+// selected by its filter.
+func (mongoWriter *MongoWriter) OplogWriter(sourceDatabase string) *OplogWriter {
+	return &OplogWriter{
+		DatabaseName:   mongoWriter.DatabaseName,
+		SourceDatabase: sourceDatabase,
+		Filter:         mongoWriter.Filter,
+		admin:          mongoWriter.database.Client().Database("admin"),
+	}
+}
+
+// OplogWriter implements an io.Writer interface, replaying the raw BSON
+// oplog entries written to it with the applyOps command,
+// as `mongorestore --oplogReplay` does.
+type OplogWriter struct {
+	DatabaseName string
+	// SourceDatabase is the database the entries were recorded on.
+	// Their namespaces are renamed to the ones of DatabaseName.
+	SourceDatabase string
+	Filter         CollectionFilter
+	EntryCount     int64
+	admin          *mongo.Database
+	documentSplitter
+}
+
+// Write splits the written data into oplog entries and replays them,
+// as soon as they are complete.
+func (oplogWriter *OplogWriter) Write(data []byte) (int, error) {
+	return oplogWriter.write(data, oplogWriter.writeEntry)
+}
+
+// Close fails if the stream ended in the middle of an entry.
+func (oplogWriter *OplogWriter) Close() error {
+	return oplogWriter.close()
+}
+
+// writeEntry replays an oplog entry. The operations of a transaction
+// are replayed one by one.
+func (oplogWriter *OplogWriter) writeEntry(rawEntryBSON bson.Raw) error {
+	var entry bson.D
+	if err := bson.Unmarshal(rawEntryBSON, &entry); err != nil {
+		return err
+	}
+
+	if operations, ok := transactionOperations(entry); ok {
+		for _, operation := range operations {
+			if operationEntry, ok := operation.(bson.D); ok {
+				if err := oplogWriter.applyEntry(operationEntry); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return oplogWriter.applyEntry(entry)
+}
+
+// applyEntry replays an oplog entry, unless it is a no-op, ends a transaction,
+// or is about a collection left out by the filter.
+func (oplogWriter *OplogWriter) applyEntry(entry bson.D) error {
+	entry, ok := oplogWriter.renameEntry(entry)
+	if !ok {
+		return nil
+	}
+
+	err := oplogWriter.admin.RunCommand(context.TODO(), bson.D{{Key: "applyOps", Value: bson.A{entry}}}).Err()
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) && commandError.Code == duplicateKeyCode && entryValue(entry, "op") == "i" {
+		// The document was inserted before it was read, replace it instead.
+		err = oplogWriter.admin.RunCommand(context.TODO(), bson.D{{Key: "applyOps", Value: bson.A{upsertEntry(entry)}}}).Err()
+	}
+	if err != nil {
+		log.Printf("Failed to replay oplog entry %v: %s\n", entry, err)
+		return err
+	}
+	oplogWriter.EntryCount++
+	return nil
+}
+
+// renameEntry returns the entry with the fields to be replayed, in the namespace of
+// the writer's database. It returns false for entries not to be replayed.
+func (oplogWriter *OplogWriter) renameEntry(entry bson.D) (bson.D, bool) {
+	operation, _ := entryValue(entry, "op").(string)
+	namespace, _ := entryValue(entry, "ns").(string)
+	if operation == "" || operation == "n" || !strings.HasPrefix(namespace, oplogWriter.SourceDatabase+".") {
+		return nil, false
+	}
+
+	collectionName := entryCollection(oplogWriter.SourceDatabase, entry)
+	command, isCommand := entryValue(entry, "o").(bson.D)
+	if collectionName != "" && !oplogWriter.Filter.Matches(collectionName) {
+		return nil, false
+	}
+
+	var renamed bson.D
+	for _, element := range entry {
+		if !oplogEntryFields[element.Key] {
+			continue
+		}
+		switch element.Key {
+		case "ns":
+			element.Value = oplogWriter.DatabaseName + strings.TrimPrefix(namespace, oplogWriter.SourceDatabase)
+		case "o":
+			if operation == "c" && isCommand {
+				element.Value = oplogWriter.renameCommand(command)
+			}
+		}
+		renamed = append(renamed, element)
+	}
+	return renamed, true
+}
+
+// renameCommand renames the full namespaces given to a command,
+// e.g. by renameCollection, to the ones of the writer's database.
+func (oplogWriter *OplogWriter) renameCommand(command bson.D) bson.D {
+	renamed := make(bson.D, 0, len(command))
+	for _, element := range command {
+		if value, ok := element.Value.(string); ok && strings.HasPrefix(value, oplogWriter.SourceDatabase+".") {
+			element.Value = oplogWriter.DatabaseName + strings.TrimPrefix(value, oplogWriter.SourceDatabase)
+		}
+		renamed = append(renamed, element)
+	}
+	return renamed
+}
+
+// entryCollection returns the name of the collection of the database an oplog entry
+// is about, or "" for commands about the database as a whole.
+func entryCollection(databaseName string, entry bson.D) string {
+	namespace, _ := entryValue(entry, "ns").(string)
+	collectionName := strings.TrimPrefix(namespace, databaseName+".")
+	command, isCommand := entryValue(entry, "o").(bson.D)
+	if entryValue(entry, "op") == "c" && isCommand && len(command) > 0 {
+		// Commands name their collection, or its namespace, as their first value.
+		collectionName, _ = command[0].Value.(string)
+		collectionName = strings.TrimPrefix(collectionName, databaseName+".")
+	}
+	return collectionName
+}
+
+// transactionOperations returns the operations of an entry recording a transaction.
+func transactionOperations(entry bson.D) (bson.A, bool) {
+	if entryValue(entry, "op") != "c" {
+		return nil, false
+	}
+	command, ok := entryValue(entry, "o").(bson.D)
+	if !ok {
+		return nil, false
+	}
+	operations, ok := entryValue(command, "applyOps").(bson.A)
+	return operations, ok
+}
+
+// upsertEntry turns an insert entry into an update replacing the document.
+func upsertEntry(entry bson.D) bson.D {
+	document, _ := entryValue(entry, "o").(bson.D)
+	return bson.D{
+		{Key: "op", Value: "u"},
+		{Key: "ns", Value: entryValue(entry, "ns")},
+		{Key: "o2", Value: bson.D{{Key: "_id", Value: entryValue(document, "_id")}}},
+		{Key: "o", Value: document},
+	}
+}
+
+// entryValue returns the value of the key in the document, or nil.
+func entryValue(document bson.D, key string) interface{} {
+	for _, element := range document {
+		if element.Key == key {
+			return element.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// insertEntry returns the oplog entry of an insert into the namespace.
+func insertEntry(namespace string, id int) bson.D {
+	return bson.D{{Key: "op", Value: "i"}, {Key: "ns", Value: namespace}, {Key: "o", Value: bson.D{{Key: "_id", Value: id}}}}
+}
+
+// transactionEntry returns the oplog entry of a transaction of the operations.
+func transactionEntry(operations ...bson.D) bson.D {
+	applyOps := bson.A{}
+	for _, operation := range operations {
+		applyOps = append(applyOps, operation)
+	}
+	return bson.D{
+		{Key: "op", Value: "c"},
+		{Key: "ns", Value: "admin.$cmd"},
+		{Key: "o", Value: bson.D{{Key: "applyOps", Value: applyOps}, {Key: "prepare", Value: false}}},
+	}
+}
+
+func TestOplogReaderFilterEntry(t *testing.T) {
+	oplogReader := &OplogReader{DatabaseName: "testdb", Filter: CollectionFilter{Exclude: []string{"log_*"}}}
+
+	for _, test := range []struct {
+		name  string
+		entry bson.D
+		// filtered is the entry expected to be read, or nil if it is left out.
+		filtered bson.D
+	}{
+		{"selected collection", insertEntry("testdb.users", 1), insertEntry("testdb.users", 1)},
+		{"excluded collection", insertEntry("testdb.log_access", 1), nil},
+		{"other database", insertEntry("otherdb.users", 1), nil},
+		{
+			"command about an excluded collection",
+			bson.D{{Key: "op", Value: "c"}, {Key: "ns", Value: "testdb.$cmd"}, {Key: "o", Value: bson.D{{Key: "drop", Value: "log_access"}}}},
+			nil,
+		},
+		{
+			"command about the database",
+			bson.D{{Key: "op", Value: "c"}, {Key: "ns", Value: "testdb.$cmd"}, {Key: "o", Value: bson.D{{Key: "dropDatabase", Value: 1}}}},
+			bson.D{{Key: "op", Value: "c"}, {Key: "ns", Value: "testdb.$cmd"}, {Key: "o", Value: bson.D{{Key: "dropDatabase", Value: 1}}}},
+		},
+		{
+			"transaction of selected operations",
+			transactionEntry(insertEntry("testdb.users", 1), insertEntry("testdb.orders", 2)),
+			transactionEntry(insertEntry("testdb.users", 1), insertEntry("testdb.orders", 2)),
+		},
+		{
+			"transaction of some selected operations",
+			transactionEntry(insertEntry("testdb.log_access", 1), insertEntry("testdb.users", 2), insertEntry("otherdb.users", 3)),
+			transactionEntry(insertEntry("testdb.users", 2)),
+		},
+		{
+			"transaction of no selected operation",
+			transactionEntry(insertEntry("testdb.log_access", 1), insertEntry("otherdb.users", 2)),
+			nil,
+		},
+	} {
+		rawEntryBSON, err := bson.Marshal(test.entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filtered, err := oplogReader.filterEntry(rawEntryBSON)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if test.filtered == nil {
+			if filtered != nil {
+				t.Errorf("%s: got %s, want it left out", test.name, filtered)
+			}
+			continue
+		}
+		want, err := bson.Marshal(test.filtered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filtered.String() != bson.Raw(want).String() {
+			t.Errorf("%s: got %s, want %s", test.name, filtered, bson.Raw(want))
+		}
+	}
+}
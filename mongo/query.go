@@ -0,0 +1,77 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"encoding/json"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CollectionQuery selects the documents of a collection to be read, and their fields.
+// Its documents are in MongoDB extended JSON, e.g. the filter
+// {"createdAt": {"$gte": {"$date": "2020-03-01T00:00:00Z"}}}
+// or the projection {"email": 0, "phone": 0}.
+type CollectionQuery struct {
+	Filter     json.RawMessage `json:"filter"`
+	Projection json.RawMessage `json:"projection"`
+}
+
+// documents returns the filter and projection of the query as BSON,
+// or nil for the ones that are not given.
+func (query CollectionQuery) documents() (bson.Raw, bson.Raw, error) {
+	filter, err := extJSONDocument(query.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	projection, err := extJSONDocument(query.Projection)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, projection, nil
+}
+
+// Check fails for malformed queries, and for projections leaving out the _id
+// of the documents, which is needed to restore them.
+func (query CollectionQuery) Check() error {
+	_, projection, err := query.documents()
+	if err != nil {
+		return err
+	}
+	if projection == nil {
+		return nil
+	}
+	include, err := projection.LookupErr("_id")
+	if err != nil {
+		return nil
+	}
+	var excluded bool
+	switch include.Type {
+	case bson.TypeInt32:
+		excluded = include.Int32() == 0
+	case bson.TypeInt64:
+		excluded = include.Int64() == 0
+	case bson.TypeDouble:
+		excluded = include.Double() == 0
+	case bson.TypeBoolean:
+		excluded = !include.Boolean()
+	}
+	if excluded {
+		return errors.New("the projection cannot leave out _id")
+	}
+	return nil
+}
+
+// extJSONDocument converts a document in MongoDB extended JSON to BSON.
+func extJSONDocument(data json.RawMessage) (bson.Raw, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var document bson.D
+	if err := bson.UnmarshalExtJSON(data, false, &document); err != nil {
+		return nil, err
+	}
+	return bson.Marshal(document)
+}
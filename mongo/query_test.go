@@ -0,0 +1,33 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCollectionQueryCheck(t *testing.T) {
+	for _, test := range []struct {
+		filter     string
+		projection string
+		valid      bool
+	}{
+		{"", "", true},
+		{`{"createdAt": {"$gte": {"$date": "2020-03-01T00:00:00Z"}}}`, "", true},
+		{"", `{"email": 0, "phone": 0}`, true},
+		{"", `{"_id": 1, "name": 1}`, true},
+		{"", `{"_id": 0}`, false},
+		{"", `{"_id": false, "name": 1}`, false},
+		{"", `{"_id": {"$numberLong": "0"}}`, false},
+		{"", `{"_id": 0.0}`, false},
+		{`{"createdAt": `, "", false},
+		{"", `null`, true},
+	} {
+		query := CollectionQuery{Filter: json.RawMessage(test.filter), Projection: json.RawMessage(test.projection)}
+		if err := query.Check(); (err == nil) != test.valid {
+			t.Errorf("filter %s, projection %s: got %v, want valid %v", test.filter, test.projection, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// clusterTimeCollection is queried to learn the cluster time snapshot reads start at.
+// It does not need to exist.
+const clusterTimeCollection = "storj-mongodb.clusterTime"
+
+// ClusterTime returns the latest cluster time a snapshot read can be done at,
+// i.e. the time of the majority-committed data of the replica set.
+func (mongoReader *MongoReader) ClusterTime(ctx context.Context) (primitive.Timestamp, error) {
+	var reply struct {
+		Cursor struct {
+			AtClusterTime primitive.Timestamp `bson:"atClusterTime"`
+		} `bson:"cursor"`
+		OperationTime primitive.Timestamp `bson:"operationTime"`
+	}
+	err := mongoReader.database.RunCommand(ctx, bson.D{
+		{Key: "find", Value: clusterTimeCollection},
+		{Key: "limit", Value: 1},
+		{Key: "singleBatch", Value: true},
+		{Key: "readConcern", Value: bson.D{{Key: "level", Value: "snapshot"}}},
+	}).Decode(&reply)
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+
+	if reply.Cursor.AtClusterTime.T != 0 {
+		return reply.Cursor.AtClusterTime, nil
+	}
+	return reply.OperationTime, nil
+}
+
+// snapshotReadConcern returns the read concern of the snapshot reads,
+// pinned to AtClusterTime, which is set to the current cluster time if unset.
+func (mongoReader *MongoReader) snapshotReadConcern(ctx context.Context) (bson.D, error) {
+	if mongoReader.AtClusterTime.T == 0 {
+		clusterTime, err := mongoReader.ClusterTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mongoReader.AtClusterTime = clusterTime
+	}
+	return bson.D{{Key: "level", Value: "snapshot"}, {Key: "atClusterTime", Value: mongoReader.AtClusterTime}}, nil
+}
+
+// findSnapshot opens a cursor over the documents of the collection matching the filter,
+// as of AtClusterTime, sorted by _id.
+func (mongoReader *MongoReader) findSnapshot(ctx context.Context, collection *mongo.Collection, filter interface{}, projection bson.Raw) (*mongo.Cursor, error) {
+	readConcern, err := mongoReader.snapshotReadConcern(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	command := bson.D{
+		{Key: "find", Value: collection.Name()},
+		{Key: "filter", Value: filter},
+		{Key: "sort", Value: bson.D{{Key: "_id", Value: 1}}},
+	}
+	if projection != nil {
+		command = append(command, bson.E{Key: "projection", Value: projection})
+	}
+	command = append(command, bson.E{Key: "readConcern", Value: readConcern})
+	return mongoReader.database.RunCommandCursor(ctx, command)
+}
+
+// countSnapshot counts the documents of the collection matching the filter,
+// as of AtClusterTime.
+func (mongoReader *MongoReader) countSnapshot(ctx context.Context, collection *mongo.Collection, filter interface{}) (int64, error) {
+	readConcern, err := mongoReader.snapshotReadConcern(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := mongoReader.database.RunCommandCursor(ctx, bson.D{
+		{Key: "aggregate", Value: collection.Name()},
+		{Key: "pipeline", Value: bson.A{
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$count", Value: "count"}},
+		}},
+		{Key: "cursor", Value: bson.D{}},
+		{Key: "readConcern", Value: readConcern},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Count int64 `bson:"count"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Count, cursor.Err()
+}
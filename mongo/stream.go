@@ -0,0 +1,95 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StreamFormatVersion is the version of the framed stream produced by MongoReader.
+//
+// A framed stream starts with a StreamHeader document. Every collection's
+// documents are preceded by a CollectionHeader document, so that the
+// documents can be put back into the collection they were read from.
+// All frames are plain BSON documents, told apart by their first key.
+const StreamFormatVersion = 1
+
+// Keys that mark the frames of a framed stream.
+// Only the first key of a document is looked at. MongoDB stores every document
+// with _id as its first field, and CollectionQuery.Check refuses projections
+// leaving it out, hence the documents read always start with _id and cannot
+// be taken for frames, even with '$'-prefixed fields, allowed since MongoDB 5.0.
+const (
+	streamHeaderKey     = "$storjMongoDB"
+	collectionHeaderKey = "$collection"
+)
+
+// Kinds of documents found in a framed stream.
+const (
+	dataDocument = iota
+	streamHeaderDocument
+	collectionHeaderDocument
+)
+
+// StreamHeader is the first document of a framed stream.
+type StreamHeader struct {
+	Version  int32  `bson:"$storjMongoDB"`
+	Database string `bson:"database"`
+}
+
+// CollectionHeader precedes the documents of a collection in a framed stream.
+type CollectionHeader struct {
+	Name string `bson:"$collection"`
+	// Type is "collection", "view" or "timeseries". Views are followed by no documents.
+	Type string `bson:"type,omitempty"`
+	// Options the collection was created with, as listed by MongoDB.
+	Options bson.Raw `bson:"options,omitempty"`
+	// Count is the number of documents in the collection when it was opened for reading.
+	Count int64 `bson:"count"`
+	// Indexes are the specifications of the collection's indexes, as listed by MongoDB.
+	Indexes []bson.Raw `bson:"indexes,omitempty"`
+}
+
+// documentKind tells whether a document of a stream is a frame or data.
+func documentKind(rawDocumentBSON bson.Raw) int {
+	element, err := rawDocumentBSON.IndexErr(0)
+	if err != nil {
+		// Empty document.
+		return dataDocument
+	}
+	switch element.Key() {
+	case streamHeaderKey:
+		return streamHeaderDocument
+	case collectionHeaderKey:
+		return collectionHeaderDocument
+	default:
+		return dataDocument
+	}
+}
+
+// parseStreamHeader decodes and checks the header of a framed stream.
+func parseStreamHeader(rawDocumentBSON bson.Raw) (StreamHeader, error) {
+	var header StreamHeader
+	if err := bson.Unmarshal(rawDocumentBSON, &header); err != nil {
+		return header, fmt.Errorf("%w: invalid stream header: %v", ErrCorruptStream, err)
+	}
+	if header.Version < 1 || header.Version > StreamFormatVersion {
+		return header, fmt.Errorf("unsupported stream format version %d", header.Version)
+	}
+	return header, nil
+}
+
+// parseCollectionHeader decodes the header of a collection in a framed stream.
+func parseCollectionHeader(rawDocumentBSON bson.Raw) (CollectionHeader, error) {
+	var header CollectionHeader
+	if err := bson.Unmarshal(rawDocumentBSON, &header); err != nil {
+		return header, fmt.Errorf("%w: invalid collection header: %v", ErrCorruptStream, err)
+	}
+	if header.Name == "" {
+		return header, fmt.Errorf("%w: collection header without name", ErrCorruptStream)
+	}
+	return header, nil
+}
@@ -0,0 +1,32 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDocumentKind(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		document interface{}
+		kind     int
+	}{
+		{"stream header", StreamHeader{Version: StreamFormatVersion, Database: "testdb"}, streamHeaderDocument},
+		{"collection header", CollectionHeader{Name: "users", Count: 2}, collectionHeaderDocument},
+		{"document", bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "ada"}}, dataDocument},
+		{"document with '$'-prefixed fields", bson.D{{Key: "_id", Value: 1}, {Key: "$collection", Value: "users"}}, dataDocument},
+		{"empty document", bson.D{}, dataDocument},
+	} {
+		rawDocumentBSON, err := bson.Marshal(test.document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind := documentKind(rawDocumentBSON); kind != test.kind {
+			t.Errorf("%s: got kind %d, want %d", test.name, kind, test.kind)
+		}
+	}
+}
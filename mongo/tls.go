@@ -0,0 +1,88 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuthMechanismX509 authenticates with the client certificate of the TLS connection.
+const AuthMechanismX509 = "MONGODB-X509"
+
+// externalAuthSource is the authentication database of users authenticated
+// outside of MongoDB, e.g. by their x.509 certificate.
+const externalAuthSource = "$external"
+
+// usesTLS tells whether the configuration asks for a TLS connection.
+func usesTLS(configMongoDB ConfigMongoDB) bool {
+	return configMongoDB.TLS || configMongoDB.TLSCAFile != "" || configMongoDB.TLSCertificateFile != "" ||
+		configMongoDB.TLSInsecureSkipVerify || configMongoDB.AuthMechanism == AuthMechanismX509
+}
+
+// tlsConfig returns the TLS configuration of the connection,
+// trusting the CA file and presenting the client certificate, if given.
+func tlsConfig(configMongoDB ConfigMongoDB) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: configMongoDB.TLSInsecureSkipVerify}
+
+	if configMongoDB.TLSCAFile != "" {
+		caPEM, err := ioutil.ReadFile(configMongoDB.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no CA certificate found in %s", configMongoDB.TLSCAFile)
+		}
+	}
+
+	if configMongoDB.TLSCertificateFile != "" {
+		// The private key may be in the certificate file, as with mongo's tlsCertificateKeyFile.
+		keyFile := configMongoDB.TLSPrivateKeyFile
+		if keyFile == "" {
+			keyFile = configMongoDB.TLSCertificateFile
+		}
+		certificate, err := tls.LoadX509KeyPair(configMongoDB.TLSCertificateFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	} else if configMongoDB.AuthMechanism == AuthMechanismX509 {
+		return nil, fmt.Errorf("%s authentication needs a client certificate file", AuthMechanismX509)
+	}
+
+	return config, nil
+}
+
+// applySecurity sets the TLS configuration and the authentication mechanism
+// of the configuration, if any, on the client options.
+func applySecurity(clientOptions *options.ClientOptions, configMongoDB ConfigMongoDB) error {
+	if usesTLS(configMongoDB) {
+		config, err := tlsConfig(configMongoDB)
+		if err != nil {
+			return err
+		}
+		clientOptions.SetTLSConfig(config)
+	}
+
+	if configMongoDB.AuthMechanism != "" {
+		var credential options.Credential
+		if clientOptions.Auth != nil {
+			credential = *clientOptions.Auth
+		}
+		credential.AuthMechanism = configMongoDB.AuthMechanism
+		if configMongoDB.AuthMechanism == AuthMechanismX509 {
+			// The certificate authenticates the user, who has no password.
+			credential.AuthSource = externalAuthSource
+			credential.Password = ""
+			credential.PasswordSet = false
+		}
+		clientOptions.SetAuth(credential)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DocumentValidator implements an io.Writer interface, checking that the
+// written data is a valid stream of BSON documents, as restore reads it,
+// and counting the documents of each collection of a framed stream.
+type DocumentValidator struct {
+	// Collections count the documents, and their bytes, of the collections
+	// whose headers were written, in the order of the stream.
+	Collections   []CollectionStats
+	DocumentCount int64
+	documentSplitter
+}
+
+// Write splits the written data into documents and checks them,
+// as soon as they are complete.
+func (validator *DocumentValidator) Write(data []byte) (int, error) {
+	return validator.write(data, validator.writeDocument)
+}
+
+// Close fails if the stream ended in the middle of a document.
+func (validator *DocumentValidator) Close() error {
+	return validator.close()
+}
+
+// writeDocument checks a frame, or counts a document of the current collection.
+func (validator *DocumentValidator) writeDocument(rawDocumentBSON bson.Raw) error {
+	switch documentKind(rawDocumentBSON) {
+	case streamHeaderDocument:
+		_, err := parseStreamHeader(rawDocumentBSON)
+		return err
+	case collectionHeaderDocument:
+		header, err := parseCollectionHeader(rawDocumentBSON)
+		if err != nil {
+			return err
+		}
+		validator.Collections = append(validator.Collections, CollectionStats{Name: header.Name, Type: header.Type})
+		return nil
+	}
+
+	validator.DocumentCount++
+	if len(validator.Collections) > 0 {
+		current := &validator.Collections[len(validator.Collections)-1]
+		current.Documents++
+		current.Bytes += int64(len(rawDocumentBSON))
+	}
+	return nil
+}
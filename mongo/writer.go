@@ -0,0 +1,258 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxDocumentSize is the largest BSON document accepted from a backup stream,
+// i.e. MongoDB's 16 MB document limit plus room for the command overhead.
+const maxDocumentSize = 16*1024*1024 + 16*1024
+
+// insertBatchCount and insertBatchSize limit how many documents,
+// and how many of their bytes, are inserted into MongoDB at once.
+const (
+	insertBatchCount = 1000
+	insertBatchSize  = 8 * 1024 * 1024
+)
+
+// ErrCorruptStream is returned when the written data is not a valid sequence of BSON documents.
+var ErrCorruptStream = errors.New("corrupt BSON stream")
+
+// MongoWriter implements an io.Writer interface
+type MongoWriter struct {
+	DatabaseName string
+	// CollectionName is the collection documents are inserted into,
+	// until a collection header of a framed stream names another one.
+	CollectionName string
+	DocumentCount  int64
+	database       *mongo.Database
+	documentSplitter
+	batch       []interface{}
+	batchSize   int
+	header      *CollectionHeader
+	headerCount int64
+	// BuildIndexesLast delays creating a collection's indexes until
+	// its documents are inserted, instead of creating them first.
+	BuildIndexesLast bool
+	// Filter selects the collections of a framed stream to be restored.
+	Filter CollectionFilter
+	// skipping is set while the documents of a collection left out by the filter are written.
+	skipping bool
+}
+
+// Write splits the written raw BSON data into documents and
+// inserts them into the collection, as soon as they are complete.
+// A document spanning several calls is kept until its remaining bytes arrive.
+// Both framed streams and plain concatenated BSON documents are accepted.
+func (mongoWriter *MongoWriter) Write(data []byte) (int, error) { // data represents the byte array, that is to be restored
+	numOfBytesWritten, err := mongoWriter.write(data, mongoWriter.writeDocument)
+	// The magic number starting an archive is no valid document size.
+	if errors.Is(err, ErrCorruptStream) && isArchive(mongoWriter.pending) {
+		return 0, errors.New("object is a mongodump archive, restore it with mongorestore --archive")
+	}
+	return numOfBytesWritten, err
+}
+
+// documentSplitter splits the raw BSON data written in several calls into
+// documents. It is embedded by the writers of BSON streams.
+type documentSplitter struct {
+	// pending holds the incomplete document of the data written so far.
+	pending []byte
+}
+
+// write passes each document the data completes to writeDocument,
+// keeping the incomplete one for the next call.
+func (splitter *documentSplitter) write(data []byte, writeDocument func(bson.Raw) error) (int, error) {
+	splitter.pending = append(splitter.pending, data...)
+
+	consumed, err := splitDocuments(splitter.pending, writeDocument)
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep only the incomplete document for the next call.
+	splitter.pending = append(splitter.pending[:0], splitter.pending[consumed:]...)
+
+	return len(data), nil
+}
+
+// close fails if the stream ended in the middle of a document.
+func (splitter *documentSplitter) close() error {
+	if len(splitter.pending) > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrCorruptStream, len(splitter.pending))
+	}
+	return nil
+}
+
+// splitDocuments passes each complete document of the data to writeDocument,
+// and returns the number of bytes of the documents passed.
+func splitDocuments(data []byte, writeDocument func(bson.Raw) error) (int, error) {
+	consumed := 0
+	for len(data)-consumed >= 4 {
+		documentSize := int(int32(binary.LittleEndian.Uint32(data[consumed:])))
+		if documentSize < 5 || documentSize > maxDocumentSize {
+			return consumed, fmt.Errorf("%w: invalid document size %d", ErrCorruptStream, documentSize)
+		}
+		if len(data)-consumed < documentSize {
+			// Wait for the rest of the document.
+			break
+		}
+
+		// Copy the document, as the pending buffer is reused.
+		rawDocumentBSON := make(bson.Raw, documentSize)
+		copy(rawDocumentBSON, data[consumed:consumed+documentSize])
+		consumed += documentSize
+
+		if err := rawDocumentBSON.Validate(); err != nil {
+			return consumed, fmt.Errorf("%w: %v", ErrCorruptStream, err)
+		}
+
+		if err := writeDocument(rawDocumentBSON); err != nil {
+			return consumed, err
+		}
+	}
+	return consumed, nil
+}
+
+// writeDocument handles a frame, or adds a document to the batch
+// to be inserted and inserts the batch once it is full.
+func (mongoWriter *MongoWriter) writeDocument(rawDocumentBSON bson.Raw) error {
+	switch documentKind(rawDocumentBSON) {
+	case streamHeaderDocument:
+		header, err := parseStreamHeader(rawDocumentBSON)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restoring backup of %s database into %s database...\n", header.Database, mongoWriter.DatabaseName)
+		return nil
+	case collectionHeaderDocument:
+		header, err := parseCollectionHeader(rawDocumentBSON)
+		if err != nil {
+			return err
+		}
+		return mongoWriter.startCollection(header)
+	}
+
+	if mongoWriter.skipping {
+		return nil
+	}
+	if mongoWriter.CollectionName == "" {
+		return errors.New("no collection given to restore the documents into")
+	}
+
+	mongoWriter.batch = append(mongoWriter.batch, rawDocumentBSON)
+	mongoWriter.batchSize += len(rawDocumentBSON)
+	mongoWriter.headerCount++
+
+	if len(mongoWriter.batch) >= insertBatchCount || mongoWriter.batchSize >= insertBatchSize {
+		return mongoWriter.flush()
+	}
+	return nil
+}
+
+// startCollection finishes the documents of the previous collection and
+// directs the following documents into the collection of the header.
+func (mongoWriter *MongoWriter) startCollection(header CollectionHeader) error {
+	if err := mongoWriter.endCollection(); err != nil {
+		return err
+	}
+
+	mongoWriter.skipping = !mongoWriter.Filter.Matches(header.Name)
+	if mongoWriter.skipping {
+		fmt.Printf("Skipping MongoDB collection %s...\n", header.Name)
+		return nil
+	}
+
+	fmt.Printf("Restoring MongoDB collection %s...\n", header.Name)
+
+	mongoWriter.CollectionName = header.Name
+	mongoWriter.header = &header
+	mongoWriter.headerCount = 0
+
+	if err := createCollection(context.TODO(), mongoWriter.database, header); err != nil {
+		return err
+	}
+	if !mongoWriter.BuildIndexesLast {
+		return createIndexes(context.TODO(), mongoWriter.database.Collection(header.Name), header.Indexes)
+	}
+	return nil
+}
+
+// endCollection inserts the remaining documents of the current collection
+// and compares their number with the one of its header.
+// The collection's indexes are created now when BuildIndexesLast is set.
+func (mongoWriter *MongoWriter) endCollection() error {
+	if err := mongoWriter.flush(); err != nil {
+		return err
+	}
+	if mongoWriter.header == nil {
+		return nil
+	}
+	if mongoWriter.headerCount != mongoWriter.header.Count {
+		log.Printf("Collection %s: restored %d documents, its header announced %d\n", mongoWriter.header.Name, mongoWriter.headerCount, mongoWriter.header.Count)
+	}
+	header := mongoWriter.header
+	mongoWriter.header = nil
+
+	if mongoWriter.BuildIndexesLast {
+		return createIndexes(context.TODO(), mongoWriter.database.Collection(header.Name), header.Indexes)
+	}
+	return nil
+}
+
+// flush inserts the batched documents into the collection.
+func (mongoWriter *MongoWriter) flush() error {
+	if len(mongoWriter.batch) == 0 {
+		return nil
+	}
+
+	collection := mongoWriter.database.Collection(mongoWriter.CollectionName)
+	result, err := collection.InsertMany(context.TODO(), mongoWriter.batch)
+	if result != nil {
+		mongoWriter.DocumentCount += int64(len(result.InsertedIDs))
+	}
+	if err != nil {
+		log.Printf("Failed to insert documents into %s collection: %s\n", mongoWriter.CollectionName, err)
+		return err
+	}
+
+	if DEBUG {
+		fmt.Printf("Inserted %d documents into '%s' collection\n", len(mongoWriter.batch), mongoWriter.CollectionName)
+	}
+
+	mongoWriter.batch = mongoWriter.batch[:0]
+	mongoWriter.batchSize = 0
+
+	return nil
+}
+
+// ForDatabase returns a writer with the same settings,
+// restoring into another database of the same MongoDB instance.
+func (mongoWriter *MongoWriter) ForDatabase(databaseName string) *MongoWriter {
+	return &MongoWriter{
+		DatabaseName:     databaseName,
+		CollectionName:   mongoWriter.CollectionName,
+		database:         mongoWriter.database.Client().Database(databaseName),
+		BuildIndexesLast: mongoWriter.BuildIndexesLast,
+		Filter:           mongoWriter.Filter,
+	}
+}
+
+// Close inserts the remaining batched documents and
+// fails if the stream ended in the middle of a document.
+func (mongoWriter *MongoWriter) Close() error {
+	if err := mongoWriter.endCollection(); err != nil {
+		return err
+	}
+	return mongoWriter.close()
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package mongo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// marshalDocuments concatenates the documents as raw BSON.
+func marshalDocuments(t *testing.T, documents ...bson.D) []byte {
+	var data []byte
+	for _, document := range documents {
+		rawDocumentBSON, err := bson.Marshal(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, rawDocumentBSON...)
+	}
+	return data
+}
+
+func TestSplitDocuments(t *testing.T) {
+	first := marshalDocuments(t, bson.D{{Key: "_id", Value: 1}})
+	second := marshalDocuments(t, bson.D{{Key: "_id", Value: 2}, {Key: "name", Value: "two"}})
+	corrupt := append([]byte(nil), first...)
+	corrupt[4] = 0x42 // Unknown element type.
+	tooLarge := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tooLarge, maxDocumentSize+1)
+
+	for _, test := range []struct {
+		name      string
+		data      []byte
+		documents int
+		consumed  int
+		corrupt   bool
+	}{
+		{"empty", nil, 0, 0, false},
+		{"complete documents", append(append([]byte(nil), first...), second...), 2, len(first) + len(second), false},
+		{"incomplete size", first[:3], 0, 0, false},
+		{"incomplete document", append(append([]byte(nil), first...), second[:len(second)-1]...), 1, len(first), false},
+		{"too small", []byte{4, 0, 0, 0, 0}, 0, 0, true},
+		{"too large", tooLarge, 0, 0, true},
+		{"invalid document", corrupt, 0, len(corrupt), true},
+	} {
+		documents := 0
+		consumed, err := splitDocuments(test.data, func(rawDocumentBSON bson.Raw) error {
+			documents++
+			return nil
+		})
+		if documents != test.documents || consumed != test.consumed || errors.Is(err, ErrCorruptStream) != test.corrupt {
+			t.Errorf("%s: got %d documents, %d bytes, %v, want %d documents, %d bytes, corrupt %v",
+				test.name, documents, consumed, err, test.documents, test.consumed, test.corrupt)
+		}
+	}
+
+	writeErr := errors.New("write failed")
+	_, err := splitDocuments(first, func(bson.Raw) error { return writeErr })
+	if err != writeErr {
+		t.Errorf("failed write: got %v, want %v", err, writeErr)
+	}
+}
+
+func TestDocumentSplitter(t *testing.T) {
+	data := marshalDocuments(t, bson.D{{Key: "_id", Value: 1}}, bson.D{{Key: "_id", Value: 2}})
+
+	// Documents are split whatever the size of the written chunks.
+	for _, chunkSize := range []int{1, 3, 7, len(data)} {
+		var splitter documentSplitter
+		var documents []bson.Raw
+		writeDocument := func(rawDocumentBSON bson.Raw) error {
+			documents = append(documents, rawDocumentBSON)
+			return nil
+		}
+		for start := 0; start < len(data); start += chunkSize {
+			end := start + chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if numOfBytes, err := splitter.write(data[start:end], writeDocument); err != nil || numOfBytes != end-start {
+				t.Fatalf("chunks of %d bytes: wrote %d bytes, %v", chunkSize, numOfBytes, err)
+			}
+		}
+		if len(documents) != 2 || !bytes.Equal(append(append([]byte(nil), documents[0]...), documents[1]...), data) {
+			t.Errorf("chunks of %d bytes: got %d documents, want 2", chunkSize, len(documents))
+		}
+		if err := splitter.close(); err != nil {
+			t.Errorf("chunks of %d bytes: close: %v", chunkSize, err)
+		}
+	}
+
+	var splitter documentSplitter
+	splitter.write(data[:len(data)-1], func(bson.Raw) error { return nil })
+	if err := splitter.close(); !errors.Is(err, ErrCorruptStream) {
+		t.Errorf("close with trailing bytes: got %v, want %v", err, ErrCorruptStream)
+	}
+}
+
+func TestMongoWriterRefusesArchive(t *testing.T) {
+	archiveStart := make([]byte, 8)
+	binary.LittleEndian.PutUint32(archiveStart, archiveMagicNumber)
+
+	_, err := (&MongoWriter{}).Write(archiveStart)
+	if err == nil || errors.Is(err, ErrCorruptStream) {
+		t.Errorf("archive written: got %v, want it refused as an archive", err)
+	}
+}
+
+func TestPendingReader(t *testing.T) {
+	chunks := [][]byte{[]byte("abc"), []byte("defgh"), []byte("ij")}
+	reader := &pendingReader{}
+	readMore := func(size int) error {
+		if len(chunks) == 0 {
+			reader.ended = true
+			return nil
+		}
+		reader.pending = append(reader.pending, chunks[0]...)
+		chunks = chunks[1:]
+		return nil
+	}
+
+	var read []byte
+	buf := make([]byte, 4)
+	for {
+		numOfBytes, err := reader.read(buf, readMore)
+		read = append(read, buf[:numOfBytes]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if numOfBytes != len(buf) {
+			t.Errorf("read %d bytes before the end, want a full buffer of %d", numOfBytes, len(buf))
+		}
+	}
+	if string(read) != "abcdefghij" {
+		t.Errorf("read %q, want %q", read, "abcdefghij")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+)
+
+// ChangesDirectory holds the change segments of a database, next to its snapshots.
+const ChangesDirectory = "changes"
+
+// ChangeSegmentExtension ends the name of the change segment objects.
+const ChangeSegmentExtension = ".changes.bson"
+
+// changeStateName names the object of the change state in the changes directory.
+const changeStateName = "state.json"
+
+// ChangeSegment describes an object of change events, uploaded as
+// <uploadPath>/<database>/changes/<first>-<last>.changes.bson,
+// named by the cluster times of its first and last events.
+type ChangeSegment struct {
+	Path   string      `json:"path"`
+	First  ClusterTime `json:"first"`
+	Last   ClusterTime `json:"last"`
+	Events int64       `json:"events"`
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256"`
+}
+
+// ChangeState records where watching the changes of a database is,
+// so that it restarts after the last uploaded segment.
+type ChangeState struct {
+	Database string `json:"database"`
+	// ResumeToken is the resume token of the change stream, in MongoDB extended JSON.
+	ResumeToken json.RawMessage `json:"resumeToken"`
+	LastSegment ChangeSegment   `json:"lastSegment"`
+	UpdateTime  time.Time       `json:"updateTime"`
+}
+
+// changesPrefix returns the path of the changes directory of the database, with a trailing slash.
+func changesPrefix(configStorj ConfigStorj, databaseName string) string {
+	return uploadPrefix(configStorj) + databaseName + "/" + ChangesDirectory + "/"
+}
+
+// UploadChanges uploads the change events read by changesReader as the next
+// segment of the database, followed by the change state resuming after it.
+func (client *Client) UploadChanges(ctx context.Context, changesReader io.Reader, databaseName string, segment ChangeSegment, resumeToken json.RawMessage) (*ChangeSegment, error) {
+	// changesReader is an io.Reader implementation that 'reads' the change events.
+	// segment gives the cluster times and number of the change events.
+	segmentPath := client.CompressedKey(changesPrefix(client.config, databaseName) +
+		fmt.Sprintf("%010d.%010d-%010d.%010d", segment.First.T, segment.First.I, segment.Last.T, segment.Last.I) +
+		ChangeSegmentExtension)
+	fmt.Fprintln(client.log, "File path: ", segmentPath)
+
+	object, err := client.Upload(ctx, segmentPath, changesReader)
+	if err != nil {
+		fmt.Fprintf(client.log, "Could not upload: %s\t", err)
+		return nil, err
+	}
+	segment.Path = object.Path
+	segment.Size = object.Size
+	segment.SHA256 = object.SHA256
+
+	stateJSON, err := json.MarshalIndent(ChangeState{
+		Database:    databaseName,
+		ResumeToken: resumeToken,
+		LastSegment: segment,
+		UpdateTime:  time.Now().UTC(),
+	}, "", "\t")
+	if err != nil {
+		return &segment, err
+	}
+	statePath := changesPrefix(client.config, databaseName) + changeStateName
+	err = client.bucket.UploadObject(ctx, statePath, bytes.NewReader(stateJSON), nil)
+	if err != nil {
+		fmt.Fprintf(client.log, "Could not upload the change state: %s\t", err)
+		return &segment, &NetworkError{Op: "upload object", Path: statePath, Err: err}
+	}
+
+	fmt.Fprintf(client.log, "Uploaded %d change events of %s database to the Storj bucket!\n", segment.Events, databaseName)
+
+	return &segment, nil
+}
+
+// ListChangeSegments returns the change segments of the database, oldest first.
+func (client *Client) ListChangeSegments(ctx context.Context, databaseName string) ([]ChangeSegment, error) {
+	objects, err := client.List(ctx, changesPrefix(client.config, databaseName), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []ChangeSegment
+	for _, object := range objects {
+		segment := ChangeSegment{Path: object.Path, Size: object.Size}
+		_, err := fmt.Sscanf(path.Base(object.Path), "%d.%d-%d.%d"+ChangeSegmentExtension,
+			&segment.First.T, &segment.First.I, &segment.Last.T, &segment.Last.I)
+		if err != nil {
+			// Not a segment, e.g. the change state.
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	// Zero-padded names sort in the order of the segments' events.
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Path < segments[j].Path })
+	return segments, nil
+}
+
+// DownloadChangeState downloads the change state of the database,
+// or returns nil if its changes have not been watched yet.
+func (client *Client) DownloadChangeState(ctx context.Context, databaseName string) (*ChangeState, error) {
+	statePath := changesPrefix(client.config, databaseName) + changeStateName
+	var stateJSON bytes.Buffer
+	_, err := client.Download(ctx, statePath, &stateJSON)
+	if IsObjectNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state ChangeState
+	if err := json.Unmarshal(stateJSON.Bytes(), &state); err != nil {
+		return nil, fmt.Errorf("could not parse change state %q: %v", statePath, err)
+	}
+	return &state, nil
+}
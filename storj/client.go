@@ -0,0 +1,157 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	libstorj "storj.io/common/storj"
+	"storj.io/storj/lib/uplink"
+)
+
+// Client is a connection to the bucket of a Storj configuration,
+// uploading, downloading, listing and deleting its objects until closed.
+type Client struct {
+	config     ConfigStorj
+	connection *storjConnection
+	bucket     objectBucket
+	scope      string
+	// log receives the progress messages.
+	log io.Writer
+}
+
+// objectBucket is the part of an *uplink.Bucket the client uses,
+// so that the client can be tested without a satellite.
+type objectBucket interface {
+	UploadObject(ctx context.Context, path libstorj.Path, data io.Reader, opts *uplink.UploadOptions) error
+	Download(ctx context.Context, path libstorj.Path) (io.ReadCloser, error)
+	ListObjects(ctx context.Context, cfg *uplink.ListOptions) (libstorj.ObjectList, error)
+	DeleteObject(ctx context.Context, path libstorj.Path) error
+}
+
+// ObjectInfo describes an object of the bucket.
+type ObjectInfo struct {
+	// Path is the full path of the object within the bucket.
+	Path string
+	// Size is the number of bytes stored, i.e. once compressed.
+	Size int64
+	// SHA256 is the hexadecimal SHA-256 of the stored data, set by Upload only.
+	SHA256 string
+	// Created is the time the object was uploaded at, set by List only.
+	Created time.Time
+}
+
+// ClientOptions are the options of NewClient and ConnectClient.
+type ClientOptions struct {
+	// CreateBucket creates the bucket when it cannot be opened, e.g. as it
+	// does not exist yet. Otherwise opening it fails with a *BucketError,
+	// so that a mistyped bucket name is not taken for an empty bucket.
+	CreateBucket bool
+	// Log receives the progress messages of the connection and of the
+	// client, instead of os.Stdout, e.g. ioutil.Discard to silence them.
+	Log io.Writer
+}
+
+// logWriter returns the writer of the progress messages.
+func (options ClientOptions) logWriter() io.Writer {
+	if options.Log == nil {
+		return os.Stdout
+	}
+	return options.Log
+}
+
+// NewClient connects to the Storj network of the configuration and opens its
+// bucket, creating it if told by the options. If keyValue is "key", the
+// scope key is derived from the API key and encryption passphrase, instead of
+// using the serialized scope key, and is restricted if restrict is "restrict".
+func NewClient(ctx context.Context, configStorj ConfigStorj, keyValue string, restrict string, options ClientOptions) (*Client, error) {
+	connection, scope, err := connectStorj(ctx, configStorj, keyValue, restrict, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{config: configStorj, connection: connection, bucket: connection.bucket, scope: scope, log: options.logWriter()}, nil
+}
+
+// ConnectClient reads Storj configuration from given file,
+// and returns a client connected to the desired Storj network.
+func ConnectClient(ctx context.Context, fullFileName string, keyValue string, restrict string, options ClientOptions) (*Client, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename
+	configStorj, err := loadStorjConfiguration(fullFileName, options.logWriter())
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(ctx, configStorj, keyValue, restrict, options)
+}
+
+// Config returns the Storj configuration of the client.
+func (client *Client) Config() ConfigStorj {
+	return client.config
+}
+
+// Scope returns the serialized scope key, which is only set when the client
+// derived it from the API key and encryption passphrase.
+func (client *Client) Scope() string {
+	return client.scope
+}
+
+// Close releases the bucket, project and uplink of the client.
+func (client *Client) Close() error {
+	if client.connection != nil {
+		client.connection.close()
+	}
+	return nil
+}
+
+// CompressedKey returns the key with the extension of the configured codec
+// appended, e.g. ".gz", so that Upload compresses the object with the codec.
+func (client *Client) CompressedKey(key string) string {
+	return key + compressionExtension(client.config.Compression)
+}
+
+// Upload uploads the data of the reader as the object at the key, compressed
+// as told by its extension, e.g. with gzip for ".gz". Download, given the same
+// key, decompresses it back. It returns the uploaded object.
+func (client *Client) Upload(ctx context.Context, key string, reader io.Reader) (ObjectInfo, error) {
+	objectReader, err := uploadObject(ctx, client.bucket, key, reader, compressionOfPath(key))
+	if err != nil {
+		return ObjectInfo{Path: key}, err
+	}
+	return ObjectInfo{Path: key, Size: objectReader.size, SHA256: objectReader.sum()}, nil
+}
+
+// Download streams the object at the key into the writer, decompressing it
+// as told by its extension, and returns the number of bytes written.
+func (client *Client) Download(ctx context.Context, key string, writer io.Writer) (int64, error) {
+	return downloadObject(ctx, client.bucket, key, writer)
+}
+
+// List returns the objects whose keys start with the prefix, which ends with
+// a slash. Unless recursive, the objects of sub-directories are left out.
+func (client *Client) List(ctx context.Context, prefix string, recursive bool) ([]ObjectInfo, error) {
+	objects, err := listObjects(ctx, client.bucket, prefix, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ObjectInfo, 0, len(objects))
+	for _, object := range objects {
+		infos = append(infos, ObjectInfo{Path: object.Path, Size: object.Size, Created: object.Created})
+	}
+	return infos, nil
+}
+
+// Delete deletes the object at the key.
+func (client *Client) Delete(ctx context.Context, key string) error {
+	if err := client.bucket.DeleteObject(ctx, key); err != nil {
+		return &NetworkError{Op: "delete object", Path: key, Err: err}
+	}
+	return nil
+}
+
+// uploadPrefix returns the configured upload path with a trailing slash.
+func (client *Client) uploadPrefix() string {
+	return uploadPrefix(client.config)
+}
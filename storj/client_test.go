@@ -0,0 +1,205 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	libstorj "storj.io/common/storj"
+	"storj.io/storj/lib/uplink"
+)
+
+// memoryBucket is an objectBucket keeping its objects in memory.
+type memoryBucket struct {
+	objects map[string][]byte
+	deleted []string
+}
+
+func newMemoryBucket() *memoryBucket {
+	return &memoryBucket{objects: map[string][]byte{}}
+}
+
+func (bucket *memoryBucket) UploadObject(ctx context.Context, path libstorj.Path, data io.Reader, opts *uplink.UploadOptions) error {
+	content, err := ioutil.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	bucket.objects[path] = content
+	return nil
+}
+
+func (bucket *memoryBucket) Download(ctx context.Context, path libstorj.Path) (io.ReadCloser, error) {
+	content, ok := bucket.objects[path]
+	if !ok {
+		return nil, libstorj.ErrObjectNotFound.New("%q", path)
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (bucket *memoryBucket) ListObjects(ctx context.Context, cfg *uplink.ListOptions) (libstorj.ObjectList, error) {
+	list := libstorj.ObjectList{Prefix: cfg.Prefix}
+	prefixes := map[string]bool{}
+	var paths []string
+	for path := range bucket.objects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if !strings.HasPrefix(path, cfg.Prefix) {
+			continue
+		}
+		relPath := strings.TrimPrefix(path, cfg.Prefix)
+		if slash := strings.Index(relPath, "/"); slash >= 0 && !cfg.Recursive {
+			if !prefixes[relPath[:slash+1]] {
+				prefixes[relPath[:slash+1]] = true
+				list.Items = append(list.Items, libstorj.Object{Path: relPath[:slash+1], IsPrefix: true})
+			}
+			continue
+		}
+		object := libstorj.Object{Path: relPath, Created: time.Now()}
+		object.Size = int64(len(bucket.objects[path]))
+		list.Items = append(list.Items, object)
+	}
+	return list, nil
+}
+
+func (bucket *memoryBucket) DeleteObject(ctx context.Context, path libstorj.Path) error {
+	if _, ok := bucket.objects[path]; !ok {
+		return libstorj.ErrObjectNotFound.New("%q", path)
+	}
+	delete(bucket.objects, path)
+	bucket.deleted = append(bucket.deleted, path)
+	return nil
+}
+
+func newMemoryClient(configStorj ConfigStorj) (*Client, *memoryBucket) {
+	bucket := newMemoryBucket()
+	return &Client{config: configStorj, bucket: bucket, log: ioutil.Discard}, bucket
+}
+
+func TestClientUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("storj-mongodb "), 1000)
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		client, bucket := newMemoryClient(ConfigStorj{Compression: compression})
+		key := client.CompressedKey("backups/testdb/notes.bson")
+
+		object, err := client.Upload(ctx, key, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: upload: %v", compression, err)
+		}
+		if object.Path != key {
+			t.Errorf("%s: uploaded to %q, want %q", compression, object.Path, key)
+		}
+		stored := bucket.objects[key]
+		if object.Size != int64(len(stored)) {
+			t.Errorf("%s: size %d, want %d", compression, object.Size, len(stored))
+		}
+		sum := sha256.Sum256(stored)
+		if object.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: SHA-256 %s does not match the stored data", compression, object.SHA256)
+		}
+		if compression != CompressionNone && len(stored) >= len(data) {
+			t.Errorf("%s: stored %d bytes of %d, want them compressed", compression, len(stored), len(data))
+		}
+
+		var downloaded bytes.Buffer
+		numOfBytes, err := client.Download(ctx, key, &downloaded)
+		if err != nil {
+			t.Fatalf("%s: download: %v", compression, err)
+		}
+		if numOfBytes != int64(len(data)) || !bytes.Equal(downloaded.Bytes(), data) {
+			t.Errorf("%s: downloaded %d bytes differing from the uploaded ones", compression, numOfBytes)
+		}
+	}
+}
+
+func TestClientListDelete(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newMemoryClient(ConfigStorj{UploadPath: "backups"})
+	for _, key := range []string{"backups/a.bson", "backups/db/b.bson", "backups/db/c/d.bson", "other/e.bson"} {
+		if _, err := client.Upload(ctx, key, strings.NewReader("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		prefix    string
+		recursive bool
+		paths     []string
+	}{
+		{"backups/", false, []string{"backups/a.bson"}},
+		{"backups/", true, []string{"backups/a.bson", "backups/db/b.bson", "backups/db/c/d.bson"}},
+		{"backups/db/", false, []string{"backups/db/b.bson"}},
+		{"missing/", true, nil},
+	} {
+		objects, err := client.List(ctx, test.prefix, test.recursive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, object := range objects {
+			paths = append(paths, object.Path)
+		}
+		if strings.Join(paths, ",") != strings.Join(test.paths, ",") {
+			t.Errorf("List(%q, %v) = %v, want %v", test.prefix, test.recursive, paths, test.paths)
+		}
+	}
+
+	if err := client.Delete(ctx, "backups/a.bson"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Download(ctx, "backups/a.bson", ioutil.Discard)
+	if !IsObjectNotFound(err) {
+		t.Errorf("download of a deleted object: got %v, want object not found", err)
+	}
+	if err := client.Delete(ctx, "backups/a.bson"); err == nil {
+		t.Error("delete of a deleted object: got no error")
+	}
+}
+
+func TestDownloadChangeState(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newMemoryClient(ConfigStorj{UploadPath: "backups"})
+
+	state, err := client.DownloadChangeState(ctx, "testdb")
+	if err != nil || state != nil {
+		t.Fatalf("change state of an unwatched database: got %v, %v, want none", state, err)
+	}
+
+	segment := ChangeSegment{First: ClusterTime{T: 10, I: 1}, Last: ClusterTime{T: 12, I: 3}, Events: 2}
+	if _, err := client.UploadChanges(ctx, strings.NewReader("events"), "testdb", segment, []byte(`{"_data":"token"}`)); err != nil {
+		t.Fatal(err)
+	}
+	state, err = client.DownloadChangeState(ctx, "testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resumeToken bytes.Buffer
+	if err := json.Compact(&resumeToken, state.ResumeToken); err != nil {
+		t.Fatal(err)
+	}
+	if state.LastSegment.Last != segment.Last || resumeToken.String() != `{"_data":"token"}` {
+		t.Errorf("change state %+v does not resume after the uploaded segment", state)
+	}
+
+	segments, err := client.ListChangeSegments(ctx, "testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0].First != segment.First || segments[0].Last != segment.Last {
+		t.Errorf("change segments %+v, want the uploaded one", segments)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs compressing the uploaded objects, configured by the
+// "compression" key of the Storj configuration.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// compressionMetadataKey is the object metadata recording the codec of an object.
+const compressionMetadataKey = "compression"
+
+// compressionExtensions end the object paths of the compressed objects.
+var compressionExtensions = map[string]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// checkCompression fails for codecs other than the supported ones.
+// An empty codec means no compression.
+func checkCompression(codec string) error {
+	if codec == "" || codec == CompressionNone {
+		return nil
+	}
+	if _, ok := compressionExtensions[codec]; !ok {
+		return fmt.Errorf("unknown compression %q, use %q, %q or %q", codec, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	return nil
+}
+
+// compressionExtension returns the extension appended to the path of objects compressed with the codec.
+func compressionExtension(codec string) string {
+	return compressionExtensions[codec]
+}
+
+// compressionOfPath returns the codec an object was compressed with, from its path.
+func compressionOfPath(objectPath string) string {
+	for codec, extension := range compressionExtensions {
+		if strings.HasSuffix(objectPath, extension) {
+			return codec
+		}
+	}
+	return CompressionNone
+}
+
+// compressReader returns a reader of the data of the given reader,
+// compressed with the codec. It must be closed once it is no longer read.
+func compressReader(reader io.Reader, codec string) io.ReadCloser {
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		var compressor io.WriteCloser
+		var err error
+		switch codec {
+		case CompressionGzip:
+			compressor = gzip.NewWriter(pipeWriter)
+		case CompressionZstd:
+			compressor, err = zstd.NewWriter(pipeWriter)
+		default:
+			err = checkCompression(codec)
+		}
+		if err == nil {
+			_, err = io.Copy(compressor, reader)
+			if closeErr := compressor.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	return pipeReader
+}
+
+// decompressReader returns a reader of the data of the given reader,
+// decompressed with the codec. It must be closed once it is no longer read.
+func decompressReader(reader io.Reader, codec string) (io.ReadCloser, error) {
+	switch codec {
+	case CompressionGzip:
+		return gzip.NewReader(reader)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{decoder}, nil
+	default:
+		return ioutil.NopCloser(reader), nil
+	}
+}
+
+// zstdReadCloser closes a zstd decoder as an io.ReadCloser.
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+// Close releases the resources of the decoder.
+func (decoder zstdReadCloser) Close() error {
+	decoder.Decoder.Close()
+	return nil
+}
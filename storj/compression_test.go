@@ -0,0 +1,40 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import "testing"
+
+func TestCompressionOfPath(t *testing.T) {
+	for _, test := range []struct {
+		objectPath string
+		codec      string
+	}{
+		{"backups/testdb/2020-04-12_10:00:00.bson", CompressionNone},
+		{"backups/testdb/2020-04-12_10:00:00.bson.gz", CompressionGzip},
+		{"backups/testdb/2020-04-12_10:00:00.archive.zst", CompressionZstd},
+		{"backups/testdb/2020-04-12_10:00:00.manifest.json", CompressionNone},
+		{"backups/gz/notes.bson", CompressionNone},
+	} {
+		if codec := compressionOfPath(test.objectPath); codec != test.codec {
+			t.Errorf("compressionOfPath(%q) = %q, want %q", test.objectPath, codec, test.codec)
+		}
+	}
+}
+
+func TestCheckCompression(t *testing.T) {
+	for _, test := range []struct {
+		codec string
+		valid bool
+	}{
+		{"", true},
+		{CompressionNone, true},
+		{CompressionGzip, true},
+		{CompressionZstd, true},
+		{"bzip2", false},
+	} {
+		if err := checkCompression(test.codec); (err == nil) != test.valid {
+			t.Errorf("checkCompression(%q) = %v, want valid %v", test.codec, err, test.valid)
+		}
+	}
+}
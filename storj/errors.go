@@ -0,0 +1,85 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"errors"
+	"fmt"
+
+	libstorj "storj.io/common/storj"
+)
+
+// ConfigError is returned when the Storj configuration cannot be read or is invalid.
+type ConfigError struct {
+	// File is the configuration file.
+	File string
+	Err  error
+}
+
+func (err *ConfigError) Error() string {
+	return fmt.Sprintf("storj configuration %s: %v", err.File, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *ConfigError) Unwrap() error { return err.Err }
+
+// AuthError is returned when the API key, encryption passphrase or
+// serialized scope key cannot be parsed, derived or restricted.
+type AuthError struct {
+	// Op is what failed, e.g. "parse API key".
+	Op  string
+	Err error
+}
+
+func (err *AuthError) Error() string {
+	return fmt.Sprintf("could not %s: %v", err.Op, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *AuthError) Unwrap() error { return err.Err }
+
+// NetworkError is returned when the Storj network cannot be reached,
+// or fails to upload, download, list or delete an object.
+type NetworkError struct {
+	// Op is what failed, e.g. "open project" or "download object".
+	Op string
+	// Path is the path of the object, if any.
+	Path string
+	Err  error
+}
+
+func (err *NetworkError) Error() string {
+	if err.Path != "" {
+		return fmt.Sprintf("could not %s at %q: %v", err.Op, err.Path, err.Err)
+	}
+	return fmt.Sprintf("could not %s: %v", err.Op, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *NetworkError) Unwrap() error { return err.Err }
+
+// BucketError is returned when the bucket cannot be opened,
+// nor created when the client is told to.
+type BucketError struct {
+	Bucket string
+	// Op is what failed, e.g. "create bucket".
+	Op  string
+	Err error
+}
+
+func (err *BucketError) Error() string {
+	return fmt.Sprintf("could not %s %q: %v", err.Op, err.Bucket, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *BucketError) Unwrap() error { return err.Err }
+
+// IsObjectNotFound tells whether the error is about an object missing from the bucket.
+func IsObjectNotFound(err error) bool {
+	var networkError *NetworkError
+	if errors.As(err, &networkError) {
+		err = networkError.Err
+	}
+	return libstorj.ErrObjectNotFound.Has(err)
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotInfo describes the objects uploaded by a store run for a database,
+// i.e. the objects under <uploadPath>/<database>/ named by the run's timestamp,
+// or the manifest of a run storing several databases.
+type SnapshotInfo struct {
+	// Database is empty for the manifest of a run storing several databases.
+	Database string `json:"database"`
+	// Snapshot is <database>/<timestamp>, relative to the upload path,
+	// or <timestamp> for the manifest of a run storing several databases.
+	Snapshot string `json:"snapshot"`
+	// Time is the local time the snapshot's timestamp names.
+	Time    time.Time `json:"time"`
+	Objects int       `json:"objects"`
+	Size    int64     `json:"size"`
+	// Manifest is the full path of the snapshot's manifest, if it has one.
+	Manifest   string `json:"manifest,omitempty"`
+	AgeSeconds int64  `json:"ageSeconds"`
+	// Paths are the full paths of the snapshot's objects.
+	Paths []string `json:"paths"`
+}
+
+// ListSnapshots lists the objects under the upload path, of the database if given,
+// and returns them grouped into snapshots, by database and oldest first.
+// The manifests of runs storing several databases are listed first, unless the
+// database is given, and change segments are left out.
+func (client *Client) ListSnapshots(ctx context.Context, databaseName string) ([]SnapshotInfo, error) {
+	prefix := client.uploadPrefix()
+	listPrefix := prefix
+	if databaseName != "" {
+		listPrefix += databaseName + "/"
+	}
+	objects, err := client.List(ctx, listPrefix, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshots := map[string]*SnapshotInfo{}
+	for _, object := range objects {
+		database, timestamp, ok := snapshotOf(strings.TrimPrefix(object.Path, prefix))
+		if !ok {
+			continue
+		}
+		snapshotTime, err := time.ParseInLocation("2006-01-02_15:04:05", timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		name := timestamp
+		if database != "" {
+			name = database + "/" + timestamp
+		}
+		snapshot := snapshots[name]
+		if snapshot == nil {
+			snapshot = &SnapshotInfo{
+				Database:   database,
+				Snapshot:   name,
+				Time:       snapshotTime,
+				AgeSeconds: int64(now.Sub(snapshotTime).Seconds()),
+			}
+			snapshots[snapshot.Snapshot] = snapshot
+		}
+		snapshot.Objects++
+		snapshot.Size += object.Size
+		snapshot.Paths = append(snapshot.Paths, object.Path)
+		if IsManifestPath(object.Path) {
+			snapshot.Manifest = object.Path
+		}
+	}
+
+	list := make([]SnapshotInfo, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		list = append(list, *snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Database != list[j].Database {
+			return list[i].Database < list[j].Database
+		}
+		return list[i].Time.Before(list[j].Time)
+	})
+	return list, nil
+}
+
+// snapshotOf returns the database and timestamp of the snapshot an object belongs to,
+// from its path relative to the upload path: <database>/<timestamp>.<extension>
+// for objects of a snapshot, <database>/<timestamp>/<collection>.<extension>
+// for the collections of a snapshot with one object per collection, or
+// <timestamp>.manifest.json, with no database, for the manifest of a run.
+func snapshotOf(objectPath string) (string, string, bool) {
+	parts := strings.Split(objectPath, "/")
+	switch {
+	case len(parts) == 1 && IsManifestPath(parts[0]):
+		return "", strings.TrimSuffix(parts[0], ManifestExtension), true
+	case len(parts) == 2:
+		return parts[0], strings.SplitN(parts[1], ".", 2)[0], true
+	case len(parts) == 3 && parts[1] != ChangesDirectory:
+		return parts[0], parts[1], true
+	}
+	return "", "", false
+}
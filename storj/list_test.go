@@ -0,0 +1,31 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import "testing"
+
+func TestSnapshotOf(t *testing.T) {
+	for _, test := range []struct {
+		objectPath string
+		database   string
+		timestamp  string
+		ok         bool
+	}{
+		{"testdb/2020-04-12_10:00:00.bson", "testdb", "2020-04-12_10:00:00", true},
+		{"testdb/2020-04-12_10:00:00.bson.gz", "testdb", "2020-04-12_10:00:00", true},
+		{"testdb/2020-04-12_10:00:00.manifest.json", "testdb", "2020-04-12_10:00:00", true},
+		{"testdb/2020-04-12_10:00:00/users.bson.zst", "testdb", "2020-04-12_10:00:00", true},
+		{"2020-04-12_10:00:00.manifest.json", "", "2020-04-12_10:00:00", true},
+		{"testdb/changes/0000000001.0000000001-0000000002.0000000001.changes.bson", "", "", false},
+		{"testdb/changes/state.json", "", "", false},
+		{"notes.bson", "", "", false},
+		{"a/b/c/d.bson", "", "", false},
+	} {
+		database, timestamp, ok := snapshotOf(test.objectPath)
+		if database != test.database || timestamp != test.timestamp || ok != test.ok {
+			t.Errorf("snapshotOf(%q) = %q, %q, %v, want %q, %q, %v",
+				test.objectPath, database, timestamp, ok, test.database, test.timestamp, test.ok)
+		}
+	}
+}
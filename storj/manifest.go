@@ -0,0 +1,268 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestExtension ends the name of the manifest object of a snapshot.
+const ManifestExtension = ".manifest.json"
+
+// OplogExtension ends the name of the oplog object of a snapshot.
+const OplogExtension = ".oplog.bson"
+
+// Manifest describes the content of a snapshot. It is uploaded as
+// <uploadPath>/<snapshot>.manifest.json next to the snapshot's data objects.
+type Manifest struct {
+	ToolVersion   string `json:"toolVersion"`
+	Database      string `json:"database"`
+	ServerVersion string `json:"serverVersion"`
+	Format        string `json:"format"`
+	Layout        string `json:"layout"`
+	// Compression is the codec of the data objects.
+	Compression string `json:"compression"`
+	// Snapshot is <database>/<timestamp>, relative to the upload path.
+	Snapshot  string    `json:"snapshot"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// AtClusterTime is the cluster time all collections were read at,
+	// when they were read as of the same moment.
+	AtClusterTime *ClusterTime         `json:"atClusterTime,omitempty"`
+	Collections   []ManifestCollection `json:"collections"`
+	Objects       []ManifestObject     `json:"objects"`
+	// Oplog is the object of the oplog entries recorded while the snapshot was read,
+	// which are replayed after its data objects are restored.
+	Oplog *ManifestOplog `json:"oplog,omitempty"`
+	// Databases are the snapshots of the databases stored by one run, when
+	// several are. The manifest of such a run is uploaded as
+	// <uploadPath>/<timestamp>.manifest.json and has no objects of its own.
+	Databases []string `json:"databases,omitempty"`
+}
+
+// ClusterTime is a timestamp of a MongoDB cluster, i.e. the seconds since the epoch
+// and an ordinal of the operations within that second.
+type ClusterTime struct {
+	T uint32 `json:"t"`
+	I uint32 `json:"i"`
+}
+
+// After tells whether the cluster time is later than the other one.
+func (clusterTime ClusterTime) After(other ClusterTime) bool {
+	return clusterTime.T > other.T || (clusterTime.T == other.T && clusterTime.I > other.I)
+}
+
+// ManifestCollection describes a collection read into a snapshot.
+type ManifestCollection struct {
+	Name string `json:"name"`
+	// Type is "collection", "view" or "timeseries".
+	Type string `json:"type,omitempty"`
+	// Options the collection was created with, in MongoDB extended JSON,
+	// e.g. capped sizes, validators, collation, or a view's pipeline.
+	Options json.RawMessage `json:"options,omitempty"`
+	// Filter and Projection, in MongoDB extended JSON, selected the documents
+	// read from the collection, and their fields, when it was read partially.
+	Filter     json.RawMessage `json:"filter,omitempty"`
+	Projection json.RawMessage `json:"projection,omitempty"`
+	Documents  int64           `json:"documents"`
+	// Bytes is the size of the collection's BSON documents.
+	Bytes int64 `json:"bytes"`
+	// Object is the path of the data object holding the collection,
+	// when the snapshot has one object per collection.
+	Object string `json:"object,omitempty"`
+}
+
+// ManifestObject describes a data object of a snapshot.
+type ManifestObject struct {
+	// Path is relative to the directory of the manifest,
+	// i.e. <uploadPath>/<database>/.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestOplog describes the oplog object of a snapshot.
+type ManifestOplog struct {
+	ManifestObject
+	// Start and End are the timestamps of the first and last oplog entries of the range.
+	Start   ClusterTime `json:"start"`
+	End     ClusterTime `json:"end"`
+	Entries int64       `json:"entries"`
+}
+
+// ChangesAfter returns the cluster time after which the changes of the database
+// are missing from the restored snapshot: the end of its oplog, which is replayed
+// on restore, the time it was read at, or else, as its collections were read at
+// different times, the start of the run.
+func (manifest *Manifest) ChangesAfter() ClusterTime {
+	if manifest.Oplog != nil {
+		return manifest.Oplog.End
+	}
+	if manifest.AtClusterTime != nil {
+		return *manifest.AtClusterTime
+	}
+	return ClusterTime{T: uint32(manifest.StartTime.Unix()) - 1, I: math.MaxUint32}
+}
+
+// ConsistentTime returns the time the restored data of the snapshot is consistent as of.
+func (manifest *Manifest) ConsistentTime() time.Time {
+	if manifest.Oplog != nil {
+		return time.Unix(int64(manifest.Oplog.End.T), 0).UTC()
+	}
+	if manifest.AtClusterTime != nil {
+		return time.Unix(int64(manifest.AtClusterTime.T), 0).UTC()
+	}
+	return manifest.EndTime
+}
+
+// addObject records an uploaded data object, at its path relative to the manifest.
+func (manifest *Manifest) addObject(objectPath string, object ObjectInfo) {
+	manifest.Objects = append(manifest.Objects, ManifestObject{
+		Path:   objectPath,
+		Size:   object.Size,
+		SHA256: object.SHA256,
+	})
+}
+
+// IsManifestPath tells whether the object path names the manifest of a snapshot.
+func IsManifestPath(objectPath string) bool {
+	return strings.HasSuffix(objectPath, ManifestExtension)
+}
+
+// UploadManifest uploads the manifest next to the data objects of its snapshot
+// and returns the manifest's path.
+func (client *Client) UploadManifest(ctx context.Context, manifest *Manifest) (string, error) {
+	manifestJSON, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	manifestPath := client.uploadPrefix() + manifest.Snapshot + ManifestExtension
+	fmt.Fprintln(client.log, "File path: ", manifestPath)
+
+	err = client.bucket.UploadObject(ctx, manifestPath, bytes.NewReader(manifestJSON), nil)
+	if err != nil {
+		fmt.Fprintf(client.log, "Could not upload: %s\t", err)
+		return manifestPath, &NetworkError{Op: "upload object", Path: manifestPath, Err: err}
+	}
+
+	fmt.Fprintln(client.log, "Uploading of the manifest to the Storj bucket: Completed!")
+
+	return manifestPath, nil
+}
+
+// DownloadSnapshot downloads the manifest at manifestPath, and streams all
+// data objects it lists into the given io.Writer.
+func (client *Client) DownloadSnapshot(ctx context.Context, manifestPath string, databaseWriter io.Writer) (int64, error) {
+	// manifestPath is the full path of the manifest object within the bucket.
+	// databaseWriter is an io.Writer implementation that 'writes' the
+	// downloaded data into the desired destination.
+	manifest, err := client.DownloadManifest(ctx, manifestPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var numOfBytesDownloaded int64
+	for _, object := range manifest.Objects {
+		objectPath := SnapshotObjectPath(manifestPath, object.Path)
+		fmt.Fprintf(client.log, "Downloading Object %s from bucket : Initiated...\n", objectPath)
+
+		numOfBytes, err := client.Download(ctx, objectPath, databaseWriter)
+		numOfBytesDownloaded += numOfBytes
+		if err != nil {
+			fmt.Fprintf(client.log, "Could not download: %s\t", err)
+			return numOfBytesDownloaded, err
+		}
+	}
+
+	fmt.Fprintf(client.log, "Downloaded %d bytes of %d Objects from bucket!\n", numOfBytesDownloaded, len(manifest.Objects))
+
+	return numOfBytesDownloaded, nil
+}
+
+// FindSnapshot returns the manifest, and its path, of the latest snapshot of
+// the database that is consistent as of the given time, or before it.
+func (client *Client) FindSnapshot(ctx context.Context, databaseName string, at time.Time) (*Manifest, string, error) {
+	objects, err := client.List(ctx, client.uploadPrefix()+databaseName+"/", false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Snapshots are named by the local time they started at, latest last.
+	var manifestPaths []string
+	for _, object := range objects {
+		if !IsManifestPath(object.Path) {
+			continue
+		}
+		startTime, err := time.ParseInLocation("2006-01-02_15:04:05", strings.TrimSuffix(path.Base(object.Path), ManifestExtension), time.Local)
+		if err == nil && !startTime.After(at) {
+			manifestPaths = append(manifestPaths, object.Path)
+		}
+	}
+	sort.Strings(manifestPaths)
+
+	for i := len(manifestPaths) - 1; i >= 0; i-- {
+		manifest, err := client.DownloadManifest(ctx, manifestPaths[i])
+		if err != nil {
+			return nil, "", err
+		}
+		if !manifest.ConsistentTime().After(at) {
+			return manifest, manifestPaths[i], nil
+		}
+	}
+	return nil, "", fmt.Errorf("no snapshot of %s database before %s", databaseName, at)
+}
+
+// DownloadManifest downloads and parses the manifest at manifestPath.
+func (client *Client) DownloadManifest(ctx context.Context, manifestPath string) (*Manifest, error) {
+	var manifestJSON bytes.Buffer
+	if _, err := client.Download(ctx, manifestPath, &manifestJSON); err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON.Bytes(), &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse manifest %q: %v", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+// DatabaseManifestPath returns the path of the manifest of a database's snapshot,
+// listed by the manifest at manifestPath.
+func DatabaseManifestPath(manifestPath string, snapshot string) string {
+	return SnapshotObjectPath(manifestPath, snapshot+ManifestExtension)
+}
+
+// SnapshotObjectPath returns the full path of an object listed by the manifest
+// at manifestPath, from its path relative to the manifest.
+func SnapshotObjectPath(manifestPath string, objectPath string) string {
+	return strings.TrimSuffix(manifestPath, path.Base(manifestPath)) + objectPath
+}
+
+// UploadOplog uploads the oplog entries read by oplogReader next to the
+// data objects of the manifest's snapshot, and records it in the manifest.
+func (client *Client) UploadOplog(ctx context.Context, oplogReader io.Reader, manifest *Manifest) error {
+	oplogPath := client.CompressedKey(client.uploadPrefix() + manifest.Snapshot + OplogExtension)
+	fmt.Fprintln(client.log, "File path: ", oplogPath)
+
+	object, err := client.Upload(ctx, oplogPath, oplogReader)
+	if err != nil {
+		fmt.Fprintf(client.log, "Could not upload: %s\t", err)
+		return err
+	}
+	manifest.Oplog = &ManifestOplog{ManifestObject: ManifestObject{Path: path.Base(object.Path), Size: object.Size, SHA256: object.SHA256}}
+
+	fmt.Fprintln(client.log, "Uploading of the oplog to the Storj bucket: Completed!")
+
+	return nil
+}
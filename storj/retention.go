@@ -0,0 +1,178 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy is a grandfather-father-son policy, keeping the latest
+// snapshot of each of the last Hourly hours, Daily days, Weekly weeks and
+// Monthly months in which a database has snapshots. It is configured by the
+// "retention" key of the Storj configuration.
+type RetentionPolicy struct {
+	Hourly  int `json:"hourly"`
+	Daily   int `json:"daily"`
+	Weekly  int `json:"weekly"`
+	Monthly int `json:"monthly"`
+}
+
+// ErrNoRetentionPolicy is returned when pruning with a policy keeping no period.
+var ErrNoRetentionPolicy = errors.New("no retention policy given, set the \"retention\" key of the Storj configuration")
+
+// IsEmpty tells whether the policy keeps no period, i.e. is not set.
+func (policy RetentionPolicy) IsEmpty() bool {
+	return policy.Hourly <= 0 && policy.Daily <= 0 && policy.Weekly <= 0 && policy.Monthly <= 0
+}
+
+// Keep returns the snapshots kept by the policy, by their Snapshot.
+// Only complete snapshots, i.e. with a manifest, are counted, and the latest
+// complete snapshot of each database is always kept. The snapshots without a
+// manifest and the manifests of runs storing several databases are left out,
+// as PruneSnapshots handles them apart.
+func (policy RetentionPolicy) Keep(snapshots []SnapshotInfo) map[string]bool {
+	periods := []struct {
+		count  int
+		period func(time.Time) string
+	}{
+		{policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{policy.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{policy.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	kept := map[string]bool{}
+	for _, databaseSnapshots := range snapshotsByDatabase(snapshots) {
+		// Walk from the latest snapshot, keeping the latest one of each period.
+		kept[databaseSnapshots[len(databaseSnapshots)-1].Snapshot] = true
+		for _, keep := range periods {
+			seen := map[string]bool{}
+			for i := len(databaseSnapshots) - 1; i >= 0 && len(seen) < keep.count; i-- {
+				period := keep.period(databaseSnapshots[i].Time)
+				if !seen[period] {
+					seen[period] = true
+					kept[databaseSnapshots[i].Snapshot] = true
+				}
+			}
+		}
+	}
+	return kept
+}
+
+// snapshotsByDatabase groups the complete snapshots by database, keeping their order.
+func snapshotsByDatabase(snapshots []SnapshotInfo) map[string][]SnapshotInfo {
+	databases := map[string][]SnapshotInfo{}
+	for _, snapshot := range snapshots {
+		if snapshot.Database == "" || snapshot.Manifest == "" {
+			continue
+		}
+		databases[snapshot.Database] = append(databases[snapshot.Database], snapshot)
+	}
+	return databases
+}
+
+// PruneSnapshots deletes the objects of the snapshots under the upload path, of
+// the database if given, that are not kept by the retention policy, or by the one
+// of the configuration if policy is nil, followed by the manifests of the runs
+// storing several databases that no longer restore all of them.
+// A snapshot without a manifest, left by a failed run or still being uploaded,
+// is only deleted once a later snapshot of its database is complete.
+// With dryRun, nothing is deleted.
+// It returns the snapshots pruned, or to be pruned.
+func (client *Client) PruneSnapshots(ctx context.Context, databaseName string, policy *RetentionPolicy, dryRun bool) ([]SnapshotInfo, error) {
+	if policy == nil {
+		policy = &client.config.Retention
+	}
+	if policy.IsEmpty() {
+		return nil, ErrNoRetentionPolicy
+	}
+
+	// All databases are listed, as the runs storing several of them are pruned with them.
+	snapshots, err := client.ListSnapshots(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	kept := policy.Keep(snapshots)
+	latestComplete := map[string]time.Time{}
+	for database, databaseSnapshots := range snapshotsByDatabase(snapshots) {
+		latestComplete[database] = databaseSnapshots[len(databaseSnapshots)-1].Time
+	}
+
+	remaining := map[string]bool{}
+	var pruned []SnapshotInfo
+	for _, snapshot := range snapshots {
+		latest, hasComplete := latestComplete[snapshot.Database]
+		if snapshot.Database == "" || kept[snapshot.Snapshot] ||
+			(databaseName != "" && snapshot.Database != databaseName) ||
+			(snapshot.Manifest == "" && (!hasComplete || !snapshot.Time.Before(latest))) {
+			remaining[snapshot.Snapshot] = true
+			continue
+		}
+		pruned = append(pruned, snapshot)
+		if err := client.pruneSnapshot(ctx, snapshot, dryRun); err != nil {
+			return pruned, err
+		}
+	}
+
+	// A run whose snapshot of a database is gone no longer restores, so is pruned too.
+	for _, snapshot := range snapshots {
+		if snapshot.Database != "" || snapshot.Manifest == "" {
+			continue
+		}
+		manifest, err := client.DownloadManifest(ctx, snapshot.Manifest)
+		if err != nil {
+			return pruned, err
+		}
+		complete := true
+		for _, databaseSnapshot := range manifest.Databases {
+			complete = complete && remaining[databaseSnapshot]
+		}
+		if complete {
+			continue
+		}
+		pruned = append(pruned, snapshot)
+		if err := client.pruneSnapshot(ctx, snapshot, dryRun); err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+// pruneSnapshot deletes the objects of the snapshot, unless dryRun.
+func (client *Client) pruneSnapshot(ctx context.Context, snapshot SnapshotInfo, dryRun bool) error {
+	if dryRun {
+		fmt.Fprintf(client.log, "Would delete snapshot %s: %d objects\n", snapshot.Snapshot, snapshot.Objects)
+		return nil
+	}
+
+	fmt.Fprintf(client.log, "Deleting snapshot %s: %d objects\n", snapshot.Snapshot, snapshot.Objects)
+	// Delete the manifest last, so that a failed prune leaves it listing the objects left.
+	for _, objectPath := range append(snapshotDataPaths(snapshot), snapshot.Manifest) {
+		if objectPath == "" {
+			continue
+		}
+		if err := client.Delete(ctx, objectPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotDataPaths returns the paths of the snapshot's objects, but its manifest.
+func snapshotDataPaths(snapshot SnapshotInfo) []string {
+	var paths []string
+	for _, objectPath := range snapshot.Paths {
+		if objectPath != snapshot.Manifest {
+			paths = append(paths, objectPath)
+		}
+	}
+	return paths
+}
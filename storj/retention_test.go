@@ -0,0 +1,201 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// snapshotAt returns the complete snapshot of the database, or of a run if it
+// is empty, named by the timestamp.
+func snapshotAt(t *testing.T, databaseName string, timestamp string) SnapshotInfo {
+	snapshotTime, err := time.ParseInLocation("2006-01-02_15:04:05", timestamp, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := SnapshotInfo{Database: databaseName, Snapshot: timestamp, Time: snapshotTime}
+	if databaseName != "" {
+		snapshot.Snapshot = databaseName + "/" + timestamp
+	}
+	snapshot.Manifest = "backups/" + snapshot.Snapshot + ManifestExtension
+	return snapshot
+}
+
+func TestRetentionPolicyKeep(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		policy    RetentionPolicy
+		snapshots [][2]string
+		// partial are the snapshots without a manifest.
+		partial []string
+		kept    []string
+	}{
+		{
+			name:   "latest of each of the last days",
+			policy: RetentionPolicy{Daily: 2},
+			snapshots: [][2]string{
+				{"a", "2020-04-10_10:00:00"},
+				{"a", "2020-04-10_20:00:00"},
+				{"a", "2020-04-11_10:00:00"},
+				{"a", "2020-04-12_09:00:00"},
+				{"a", "2020-04-12_18:00:00"},
+			},
+			kept: []string{"a/2020-04-11_10:00:00", "a/2020-04-12_18:00:00"},
+		},
+		{
+			name:   "periods add up",
+			policy: RetentionPolicy{Hourly: 1, Monthly: 2},
+			snapshots: [][2]string{
+				{"a", "2020-02-15_10:00:00"},
+				{"a", "2020-03-01_00:00:00"},
+				{"a", "2020-03-20_10:00:00"},
+				{"a", "2020-04-01_08:00:00"},
+				{"a", "2020-04-01_08:30:00"},
+			},
+			kept: []string{"a/2020-03-20_10:00:00", "a/2020-04-01_08:30:00"},
+		},
+		{
+			name:   "periods without snapshots are not counted",
+			policy: RetentionPolicy{Weekly: 2},
+			snapshots: [][2]string{
+				{"a", "2020-01-01_10:00:00"},
+				{"a", "2020-02-03_10:00:00"},
+				{"a", "2020-04-06_10:00:00"},
+				{"a", "2020-04-08_10:00:00"},
+			},
+			kept: []string{"a/2020-02-03_10:00:00", "a/2020-04-08_10:00:00"},
+		},
+		{
+			name:   "databases are kept apart",
+			policy: RetentionPolicy{Daily: 1},
+			snapshots: [][2]string{
+				{"a", "2020-04-11_10:00:00"},
+				{"a", "2020-04-12_10:00:00"},
+				{"b", "2020-03-01_10:00:00"},
+				{"b", "2020-03-01_11:00:00"},
+			},
+			kept: []string{"a/2020-04-12_10:00:00", "b/2020-03-01_11:00:00"},
+		},
+		{
+			name:   "latest snapshot is always kept",
+			policy: RetentionPolicy{},
+			snapshots: [][2]string{
+				{"a", "2020-04-11_10:00:00"},
+				{"a", "2020-04-12_10:00:00"},
+			},
+			kept: []string{"a/2020-04-12_10:00:00"},
+		},
+		{
+			name:   "snapshots without manifest are not counted",
+			policy: RetentionPolicy{Daily: 2},
+			snapshots: [][2]string{
+				{"a", "2020-04-10_10:00:00"},
+				{"a", "2020-04-11_10:00:00"},
+				{"a", "2020-04-12_03:00:00"},
+				{"a", "2020-04-12_15:00:00"},
+			},
+			partial: []string{"a/2020-04-11_10:00:00", "a/2020-04-12_15:00:00"},
+			kept:    []string{"a/2020-04-10_10:00:00", "a/2020-04-12_03:00:00"},
+		},
+		{
+			name:   "run manifests are left out",
+			policy: RetentionPolicy{Daily: 7},
+			snapshots: [][2]string{
+				{"", "2020-04-12_10:00:00"},
+				{"a", "2020-04-12_10:00:00"},
+			},
+			kept: []string{"a/2020-04-12_10:00:00"},
+		},
+	} {
+		var snapshots []SnapshotInfo
+		for _, snapshot := range test.snapshots {
+			snapshotInfo := snapshotAt(t, snapshot[0], snapshot[1])
+			for _, partial := range test.partial {
+				if partial == snapshotInfo.Snapshot {
+					snapshotInfo.Manifest = ""
+				}
+			}
+			snapshots = append(snapshots, snapshotInfo)
+		}
+
+		var kept []string
+		for snapshot := range test.policy.Keep(snapshots) {
+			kept = append(kept, snapshot)
+		}
+		sort.Strings(kept)
+		if strings.Join(kept, ",") != strings.Join(test.kept, ",") {
+			t.Errorf("%s: kept %v, want %v", test.name, kept, test.kept)
+		}
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	for _, dryRun := range []bool{false, true} {
+		client, bucket := newMemoryClient(ConfigStorj{UploadPath: "backups", Retention: RetentionPolicy{Daily: 1}})
+		for _, snapshot := range []string{"a/2020-04-10_10:00:00", "a/2020-04-11_10:00:00", "b/2020-04-10_10:00:00", "b/2020-04-11_10:00:00"} {
+			bucket.objects["backups/"+snapshot+".bson"] = []byte("data")
+			bucket.objects["backups/"+snapshot+ManifestExtension] = []byte("{}")
+		}
+		for _, run := range []string{"2020-04-10_10:00:00", "2020-04-11_10:00:00"} {
+			manifestJSON, err := json.Marshal(Manifest{Databases: []string{"a/" + run, "b/" + run}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bucket.objects["backups/"+run+ManifestExtension] = manifestJSON
+		}
+
+		pruned, err := client.PruneSnapshots(ctx, "a", nil, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prunedSnapshots []string
+		for _, snapshot := range pruned {
+			prunedSnapshots = append(prunedSnapshots, snapshot.Snapshot)
+		}
+		want := "a/2020-04-10_10:00:00,2020-04-10_10:00:00"
+		if strings.Join(prunedSnapshots, ",") != want {
+			t.Errorf("dry run %v: pruned %v, want %s", dryRun, prunedSnapshots, want)
+		}
+
+		var deleted []string
+		if !dryRun {
+			deleted = []string{
+				"backups/a/2020-04-10_10:00:00.bson",
+				"backups/a/2020-04-10_10:00:00.manifest.json",
+				"backups/2020-04-10_10:00:00.manifest.json",
+			}
+		}
+		if strings.Join(bucket.deleted, ",") != strings.Join(deleted, ",") {
+			t.Errorf("dry run %v: deleted %v, want %v", dryRun, bucket.deleted, deleted)
+		}
+	}
+
+	// The objects of a failed run are not counted as a snapshot to keep,
+	// and are only pruned once a later snapshot is complete.
+	client, bucket := newMemoryClient(ConfigStorj{UploadPath: "backups", Retention: RetentionPolicy{Daily: 1}})
+	bucket.objects["backups/db/2020-04-11_15:00:00/orders.bson"] = []byte("data")
+	bucket.objects["backups/db/2020-04-12_03:00:00/orders.bson"] = []byte("data")
+	bucket.objects["backups/db/2020-04-12_03:00:00.manifest.json"] = []byte("{}")
+	bucket.objects["backups/db/2020-04-12_15:00:00/orders.bson"] = []byte("data")
+	pruned, err := client.PruneSnapshots(ctx, "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"backups/db/2020-04-11_15:00:00/orders.bson"}
+	if len(pruned) != 1 || strings.Join(bucket.deleted, ",") != strings.Join(want, ",") {
+		t.Errorf("failed runs: pruned %+v, deleted %v, want %v", pruned, bucket.deleted, want)
+	}
+
+	client, _ = newMemoryClient(ConfigStorj{UploadPath: "backups"})
+	if _, err := client.PruneSnapshots(ctx, "", nil, false); err != ErrNoRetentionPolicy {
+		t.Errorf("prune without policy: got %v, want %v", err, ErrNoRetentionPolicy)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+// CollectionObject pairs a collection's name with the reader of its data.
+type CollectionObject struct {
+	Name   string
+	Reader io.Reader
+}
+
+// UploadCollections uploads the data of every collection as its own object
+// under <uploadPath>/<databaseName>/<timestamp>/.
+func (client *Client) UploadCollections(ctx context.Context, collectionObjects []CollectionObject, databaseName string, fileExtension string, manifest *Manifest) error {
+	// collectionObjects are the collections' names, with io.Reader implementations
+	// that 'read' their data, which is to be uploaded to storj V3 network.
+	// databaseName for adding dataBase name in storj V3 filename.
+	// fileExtension for the format of the data, e.g. ".bson", in storj V3 filename.
+	// manifest, if not nil, records the snapshot and its uploaded objects.
+	timeNow := time.Now().Format("2006-01-02_15:04:05")
+	snapshotPath := client.uploadPrefix() + databaseName + "/" + timeNow + "/"
+	if manifest != nil {
+		manifest.Snapshot = databaseName + "/" + timeNow
+		manifest.Compression = client.config.Compression
+	}
+
+	for _, collectionObject := range collectionObjects {
+		var filename = client.CompressedKey(collectionObject.Name + fileExtension)
+		//
+		fmt.Fprintln(client.log, "File path: ", snapshotPath+filename)
+		fmt.Fprintln(client.log, "\nUploading of the object to the Storj bucket: Initiated...")
+
+		object, err := client.Upload(ctx, snapshotPath+filename, collectionObject.Reader)
+		if err != nil {
+			fmt.Fprintf(client.log, "Could not upload: %s\t", err)
+			return err
+		}
+
+		if manifest != nil {
+			manifest.addObject(strings.TrimPrefix(object.Path, client.uploadPrefix()+databaseName+"/"), object)
+		}
+	}
+
+	fmt.Fprintln(client.log, "Uploading of the objects to the Storj bucket: Completed!")
+
+	return nil
+}
+
+// hashingReader counts and hashes the data read from a reader.
+type hashingReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
+}
+
+// newHashingReader returns a hashingReader computing the SHA-256 of the data.
+func newHashingReader(reader io.Reader) *hashingReader {
+	return &hashingReader{reader: reader, hash: sha256.New()}
+}
+
+// Read reads from the underlying reader.
+func (hashing *hashingReader) Read(buf []byte) (int, error) {
+	numOfBytesRead, err := hashing.reader.Read(buf)
+	hashing.hash.Write(buf[:numOfBytesRead])
+	hashing.size += int64(numOfBytesRead)
+	return numOfBytesRead, err
+}
+
+// sum returns the hexadecimal SHA-256 of the data read so far.
+func (hashing *hashingReader) sum() string {
+	return hex.EncodeToString(hashing.hash.Sum(nil))
+}
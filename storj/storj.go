@@ -4,19 +4,21 @@
 package storj
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	libstorj "storj.io/common/storj"
 	"storj.io/storj/lib/uplink"
 	"storj.io/storj/pkg/macaroon"
 )
@@ -35,292 +37,461 @@ type ConfigStorj struct {
 	DisallowReads        string `json:"disallowReads"`
 	DisallowWrites       string `json:"disallowWrites"`
 	DisallowDeletes      string `json:"disallowDeletes"`
+	Compression          string `json:"compression"`
+	// Retention is the policy prune deletes the snapshots it does not keep by.
+	Retention RetentionPolicy `json:"retention"`
+	// Schedule is the cron expression of the store runs of the daemon command.
+	Schedule string `json:"schedule"`
 }
 
 // LoadStorjConfiguration reads and parses the JSON file that contain Storj configuration information.
 func LoadStorjConfiguration(fullFileName string) (ConfigStorj, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename.
+	return loadStorjConfiguration(fullFileName, os.Stdout)
+}
+
+// loadStorjConfiguration reads and parses the Storj configuration file,
+// displaying the read information to log.
+func loadStorjConfiguration(fullFileName string, log io.Writer) (ConfigStorj, error) {
 
 	var configStorj ConfigStorj
 
 	fileHandle, err := os.Open(fullFileName)
 	if err != nil {
-		return configStorj, err
+		return configStorj, &ConfigError{File: fullFileName, Err: err}
 	}
 	defer fileHandle.Close()
 
 	jsonParser := json.NewDecoder(fileHandle)
-	jsonParser.Decode(&configStorj)
+	if err := jsonParser.Decode(&configStorj); err != nil {
+		return configStorj, &ConfigError{File: fullFileName, Err: err}
+	}
 
 	// Display read information.
-	fmt.Println("\nRead Storj configuration from the ", fullFileName, " file")
-	fmt.Println("\nAPI Key\t\t: ", configStorj.APIKey)
-	fmt.Println("Satellite	: ", configStorj.Satellite)
-	fmt.Println("Bucket		: ", configStorj.Bucket)
-	fmt.Println("Upload Path\t: ", configStorj.UploadPath)
-	fmt.Println("Serialized Scope Key\t: ", configStorj.SerializedScope)
+	fmt.Fprintln(log, "\nRead Storj configuration from the ", fullFileName, " file")
+	fmt.Fprintln(log, "\nAPI Key\t\t: ", configStorj.APIKey)
+	fmt.Fprintln(log, "Satellite	: ", configStorj.Satellite)
+	fmt.Fprintln(log, "Bucket		: ", configStorj.Bucket)
+	fmt.Fprintln(log, "Upload Path\t: ", configStorj.UploadPath)
+	fmt.Fprintln(log, "Serialized Scope Key\t: ", configStorj.SerializedScope)
+
+	if configStorj.Compression == "" {
+		configStorj.Compression = CompressionNone
+	}
+	fmt.Fprintln(log, "Compression\t: ", configStorj.Compression)
+	if configStorj.Schedule != "" {
+		fmt.Fprintln(log, "Schedule\t: ", configStorj.Schedule)
+	}
+	if !configStorj.Retention.IsEmpty() {
+		fmt.Fprintf(log, "Retention\t:  %d hourly, %d daily, %d weekly, %d monthly\n",
+			configStorj.Retention.Hourly, configStorj.Retention.Daily, configStorj.Retention.Weekly, configStorj.Retention.Monthly)
+	}
 
+	if err := checkCompression(configStorj.Compression); err != nil {
+		return configStorj, &ConfigError{File: fullFileName, Err: err}
+	}
 	return configStorj, nil
 }
 
-// ConnectStorjReadUploadData reads Storj configuration from given file,
-// connects to the desired Storj network.
-// It then reads data using io.Reader interface and
-// uploads it as object to the desired bucket.
-func ConnectStorjReadUploadData(fullFileName string, databaseReader io.Reader, databaseName string, keyValue string, restrict string) (string, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename
-	// databaseReader is an io.Reader implementation that 'reads' desired data,
-	// which is to be uploaded to storj V3 network.
-	// databaseName for adding dataBase name in storj V3 filename.
-	// Read Storj bucket's configuration from an external file.
-	var scope string
-	configStorj, err := LoadStorjConfiguration(fullFileName)
-	if err != nil {
-		log.Fatal("loadStorjConfiguration:", err)
+// storjConnection groups the uplink, project and bucket that are opened
+// for a Storj configuration, so that they can be closed together.
+type storjConnection struct {
+	uplink  *uplink.Uplink
+	project *uplink.Project
+	bucket  *uplink.Bucket
+}
+
+// close releases the bucket, project and uplink of the connection.
+func (connection *storjConnection) close() {
+	if connection.bucket != nil {
+		connection.bucket.Close()
+	}
+	if connection.project != nil {
+		connection.project.Close()
+	}
+	if connection.uplink != nil {
+		connection.uplink.Close()
 	}
+}
+
+// connectStorj connects to the desired Storj network and opens the bucket
+// given in the configuration, creating it if it cannot be opened and told by the options.
+// It returns the opened connection and the serialized scope key,
+// which is only set when keyValue is "key".
+func connectStorj(ctx context.Context, configStorj ConfigStorj, keyValue string, restrict string, options ClientOptions) (*storjConnection, string, error) {
+	log := options.logWriter()
+	var scope string
 
-	fmt.Println("\nCreating New Uplink...")
+	fmt.Fprintln(log, "\nCreating New Uplink...")
 
 	var cfg uplink.Config
 	// Configure the partner id
 	cfg.Volatile.UserAgent = "MongoDB"
 
-	ctx := context.Background()
-
-	uplinkstorj, err := uplink.NewUplink(ctx, &cfg)
-	if err != nil {
-		uplinkstorj.Close()
-		log.Fatal("Could not create new Uplink object:", err)
-	}
-	defer uplinkstorj.Close()
 	var serializedScope string
 	if keyValue == "key" {
-
-		fmt.Println("Parsing the API key...")
-		key, err := uplink.ParseAPIKey(configStorj.APIKey)
+		var err error
+		serializedScope, scope, err = deriveScope(ctx, cfg, configStorj, restrict, log)
 		if err != nil {
-			uplinkstorj.Close()
-			log.Fatal("Could not parse API key:", err)
+			return nil, "", err
 		}
+	} else {
+		serializedScope = configStorj.SerializedScope
+	}
+	parsedScope, err := uplink.ParseScope(serializedScope)
+	if err != nil {
+		return nil, "", &AuthError{Op: "parse serialized scope key", Err: err}
+	}
 
-		if DEBUG {
-			fmt.Println("API key \t   :", configStorj.APIKey)
-			fmt.Println("Serialized API key :", key.Serialize())
-		}
+	connection := &storjConnection{}
+	connection.uplink, err = uplink.NewUplink(ctx, &cfg)
+	if err != nil {
+		return nil, "", &NetworkError{Op: "create new Uplink object", Err: err}
+	}
+	connection.project, err = connection.uplink.OpenProject(ctx, parsedScope.SatelliteAddr, parsedScope.APIKey)
+	if err != nil {
+		connection.close()
+		return nil, "", &NetworkError{Op: "open project", Err: err}
+	}
 
-		fmt.Println("Opening Project...")
-		proj, err := uplinkstorj.OpenProject(ctx, configStorj.Satellite, key)
-		if err != nil {
-			uplinkstorj.Close()
-			log.Fatal("Could not open project:", err)
-		}
-		defer proj.Close()
+	fmt.Fprintln(log, "Opening Bucket: ", configStorj.Bucket)
 
-		// Creating an encryption key from encryption passphrase.
-		if DEBUG {
-			fmt.Println("\nGetting encryption key from pass phrase...")
+	// Open up the desired Bucket within the Project.
+	connection.bucket, err = connection.project.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
+	if err != nil && !options.CreateBucket {
+		connection.close()
+		return nil, "", &BucketError{Bucket: configStorj.Bucket, Op: "open bucket", Err: err}
+	}
+	if err != nil {
+		fmt.Fprintln(log, "Could not open bucket", configStorj.Bucket, ":", err)
+		fmt.Fprintln(log, "Trying to create new bucket....")
+		_, err1 := connection.project.CreateBucket(ctx, configStorj.Bucket, nil)
+		if err1 != nil {
+			connection.close()
+			return nil, "", &BucketError{Bucket: configStorj.Bucket, Op: "create bucket", Err: err1}
 		}
-
-		encryptionKey, err := proj.SaltedKeyFromPassphrase(ctx, configStorj.EncryptionPassphrase)
+		fmt.Fprintln(log, "Created Bucket", configStorj.Bucket)
+		fmt.Fprintln(log, "Opening created Bucket: ", configStorj.Bucket)
+		connection.bucket, err = connection.project.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
 		if err != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			log.Fatal("Could not create encryption key:", err)
+			connection.close()
+			return nil, "", &BucketError{Bucket: configStorj.Bucket, Op: "open bucket", Err: err}
 		}
+	}
 
-		// Creating an encryption context.
-		access := uplink.NewEncryptionAccessWithDefaultKey(*encryptionKey)
-		if DEBUG {
-			fmt.Println("Encryption access \t:", configStorj.EncryptionPassphrase)
-		}
+	return connection, scope, nil
+}
 
-		// Serializing the parsed access, so as to compare with the original key.
-		serializedAccess, err := access.Serialize()
-		if err != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			log.Fatal("Error Serialized key : ", err)
-		}
+// deriveScope derives the serialized scope key from the API key and encryption
+// passphrase of the configuration. It returns it along with the scope key to be
+// shown to the user, which is restricted as configured if restrict is "restrict".
+func deriveScope(ctx context.Context, cfg uplink.Config, configStorj ConfigStorj, restrict string, log io.Writer) (string, string, error) {
+	uplinkstorj, err := uplink.NewUplink(ctx, &cfg)
+	if err != nil {
+		return "", "", &NetworkError{Op: "create new Uplink object", Err: err}
+	}
+	defer uplinkstorj.Close()
 
-		if DEBUG {
-			fmt.Println("Serialized access key\t:", serializedAccess)
-		}
+	fmt.Fprintln(log, "Parsing the API key...")
+	key, err := uplink.ParseAPIKey(configStorj.APIKey)
+	if err != nil {
+		return "", "", &AuthError{Op: "parse API key", Err: err}
+	}
+
+	if DEBUG {
+		fmt.Fprintln(log, "API key \t   :", configStorj.APIKey)
+		fmt.Fprintln(log, "Serialized API key :", key.Serialize())
+	}
+
+	fmt.Fprintln(log, "Opening Project...")
+	proj, err := uplinkstorj.OpenProject(ctx, configStorj.Satellite, key)
+	if err != nil {
+		return "", "", &NetworkError{Op: "open project", Err: err}
+	}
+	defer proj.Close()
+
+	// Creating an encryption key from encryption passphrase.
+	if DEBUG {
+		fmt.Fprintln(log, "\nGetting encryption key from pass phrase...")
+	}
+
+	encryptionKey, err := proj.SaltedKeyFromPassphrase(ctx, configStorj.EncryptionPassphrase)
+	if err != nil {
+		return "", "", &AuthError{Op: "create encryption key", Err: err}
+	}
+
+	// Creating an encryption context.
+	access := uplink.NewEncryptionAccessWithDefaultKey(*encryptionKey)
+	if DEBUG {
+		fmt.Fprintln(log, "Encryption access \t:", configStorj.EncryptionPassphrase)
+	}
+
+	// Serializing the parsed access, so as to compare with the original key.
+	serializedAccess, err := access.Serialize()
+	if err != nil {
+		return "", "", &AuthError{Op: "serialize encryption access", Err: err}
+	}
+
+	if DEBUG {
+		fmt.Fprintln(log, "Serialized access key\t:", serializedAccess)
+	}
+
+	// Load the existing encryption access context
+	accessParse, err := uplink.ParseEncryptionAccess(serializedAccess)
+	if err != nil {
+		return "", "", &AuthError{Op: "parse encryption access", Err: err}
+	}
 
-		// Load the existing encryption access context
-		accessParse, err := uplink.ParseEncryptionAccess(serializedAccess)
+	var scope string
+	if restrict == "restrict" {
+		disallowRead, _ := strconv.ParseBool(configStorj.DisallowReads)
+		disallowWrite, _ := strconv.ParseBool(configStorj.DisallowWrites)
+		disallowDelete, _ := strconv.ParseBool(configStorj.DisallowDeletes)
+		userAPIKey, err := key.Restrict(macaroon.Caveat{
+			DisallowReads:   disallowRead,
+			DisallowWrites:  disallowWrite,
+			DisallowDeletes: disallowDelete,
+		})
 		if err != nil {
-			log.Fatal(err)
+			return "", "", &AuthError{Op: "restrict API key", Err: err}
 		}
-
-		if restrict == "restrict" {
-			disallowRead, _ := strconv.ParseBool(configStorj.DisallowReads)
-			disallowWrite, _ := strconv.ParseBool(configStorj.DisallowWrites)
-			disallowDelete, _ := strconv.ParseBool(configStorj.DisallowDeletes)
-			userAPIKey, err := key.Restrict(macaroon.Caveat{
-				DisallowReads:   disallowRead,
-				DisallowWrites:  disallowWrite,
-				DisallowDeletes: disallowDelete,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-			userAPIKey, userAccess, err := accessParse.Restrict(userAPIKey,
-				uplink.EncryptionRestriction{
-					Bucket:     configStorj.Bucket,
-					PathPrefix: configStorj.UploadPath,
-				},
-			)
-			if err != nil {
-				log.Fatal(err)
-			}
-			userRestrictScope := &uplink.Scope{
-				SatelliteAddr:    configStorj.Satellite,
-				APIKey:           userAPIKey,
-				EncryptionAccess: userAccess,
-			}
-			serializedRestrictScope, err := userRestrictScope.Serialize()
-			if err != nil {
-				log.Fatal(err)
-			}
-			scope = serializedRestrictScope
+		userAPIKey, userAccess, err := accessParse.Restrict(userAPIKey,
+			uplink.EncryptionRestriction{
+				Bucket:     configStorj.Bucket,
+				PathPrefix: configStorj.UploadPath,
+			},
+		)
+		if err != nil {
+			return "", "", &AuthError{Op: "restrict encryption access", Err: err}
 		}
-		userScope := &uplink.Scope{
+		userRestrictScope := &uplink.Scope{
 			SatelliteAddr:    configStorj.Satellite,
-			APIKey:           key,
-			EncryptionAccess: access,
+			APIKey:           userAPIKey,
+			EncryptionAccess: userAccess,
 		}
-		serializedScope, err = userScope.Serialize()
+		scope, err = userRestrictScope.Serialize()
 		if err != nil {
-			log.Fatal(err)
-		}
-		if restrict == "" {
-			scope = serializedScope
+			return "", "", &AuthError{Op: "serialize restricted scope key", Err: err}
 		}
-
-		proj.Close()
-		uplinkstorj.Close()
-	} else {
-		serializedScope = configStorj.SerializedScope
-
 	}
-	parsedScope, err := uplink.ParseScope(serializedScope)
-	if err != nil {
-		log.Fatal(err)
+	userScope := &uplink.Scope{
+		SatelliteAddr:    configStorj.Satellite,
+		APIKey:           key,
+		EncryptionAccess: access,
 	}
-
-	uplinkstorj, err = uplink.NewUplink(ctx, &cfg)
+	serializedScope, err := userScope.Serialize()
 	if err != nil {
-		log.Fatal("Could not create new Uplink object:", err)
+		return "", "", &AuthError{Op: "serialize scope key", Err: err}
 	}
-	proj, err := uplinkstorj.OpenProject(ctx, parsedScope.SatelliteAddr, parsedScope.APIKey)
-	if err != nil {
-		uplinkstorj.Close()
-		proj.Close()
-		log.Fatal("Could not open project:", err)
+	if restrict == "" {
+		scope = serializedScope
 	}
 
-	fmt.Println("Opening Bucket: ", configStorj.Bucket)
+	return serializedScope, scope, nil
+}
 
-	// Open up the desired Bucket within the Project.
-	bucket, err := proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
-	if err != nil {
-		fmt.Println("Could not open bucket", configStorj.Bucket, ":", err)
-		fmt.Println("Trying to create new bucket....")
-		_, err1 := proj.CreateBucket(ctx, configStorj.Bucket, nil)
-		if err1 != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			bucket.Close()
-			fmt.Printf("Could not create bucket %q:", configStorj.Bucket)
-			log.Fatal(err1)
-		} else {
-			fmt.Println("Created Bucket", configStorj.Bucket)
-		}
-		fmt.Println("Opening created Bucket: ", configStorj.Bucket)
-		bucket, err = proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
+// uploadPrefix returns the configured upload path with a trailing slash,
+// to which object names are appended.
+func uploadPrefix(configStorj ConfigStorj) string {
+	if configStorj.UploadPath == "" || strings.HasSuffix(configStorj.UploadPath, "/") {
+		return configStorj.UploadPath
+	}
+	return configStorj.UploadPath + "/"
+}
+
+// listObjects returns the objects whose paths start with the prefix, ending with a slash,
+// with their full paths. Unless recursive, the objects of sub-directories are left out.
+func listObjects(ctx context.Context, bucket objectBucket, prefix string, recursive bool) ([]libstorj.Object, error) {
+	var objects []libstorj.Object
+	listOptions := uplink.ListOptions{Prefix: prefix, Recursive: recursive, Direction: libstorj.After}
+	for {
+		list, err := bucket.ListObjects(ctx, &listOptions)
 		if err != nil {
-			fmt.Printf("Could not open bucket %q: %s", configStorj.Bucket, err)
+			return nil, &NetworkError{Op: "list objects", Path: prefix, Err: err}
 		}
+		for _, object := range list.Items {
+			if object.IsPrefix {
+				continue
+			}
+			// Listed paths are relative to the prefix.
+			object.Path = prefix + object.Path
+			objects = append(objects, object)
+		}
+		if !list.More || len(list.Items) == 0 {
+			return objects, nil
+		}
+		listOptions = listOptions.NextPage(list)
+		listOptions.Recursive = recursive
 	}
+}
 
-	defer bucket.Close()
+// ConnectStorjReadUploadData reads Storj configuration from given file,
+// connects to the desired Storj network.
+// It then reads data using io.Reader interface and
+// uploads it as object <uploadPath>/<databaseName>/<timestamp>.bson to the desired bucket.
+// It returns the serialized scope key, as Client.Scope does.
+//
+// Deprecated: use ConnectClient and Client.UploadData, which upload the data
+// in other formats and record it in a manifest.
+func ConnectStorjReadUploadData(fullFileName string, databaseReader io.Reader, databaseName string, keyValue string, restrict string) (string, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename
+	ctx := context.Background()
+
+	client, err := ConnectClient(ctx, fullFileName, keyValue, restrict, ClientOptions{CreateBucket: true})
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
 
+	return client.Scope(), client.UploadData(ctx, databaseReader, databaseName, ".bson", nil)
+}
+
+// UploadData reads data using io.Reader interface and uploads it
+// as object <uploadPath>/<databaseName>/<timestamp><fileExtension>.
+func (client *Client) UploadData(ctx context.Context, databaseReader io.Reader, databaseName string, fileExtension string, manifest *Manifest) error {
+	// databaseReader is an io.Reader implementation that 'reads' desired data,
+	// which is to be uploaded to storj V3 network.
+	// databaseName for adding dataBase name in storj V3 filename.
+	// fileExtension for the format of the data, e.g. ".bson", in storj V3 filename.
+	// manifest, if not nil, records the snapshot and its uploaded objects.
 	var fileNamesDEBUG []string
-	checkSlash := configStorj.UploadPath[len(configStorj.UploadPath)-1:]
-	if checkSlash != "/" {
-		configStorj.UploadPath = configStorj.UploadPath + "/"
+	if manifest != nil {
+		manifest.Compression = client.config.Compression
 	}
 
 	// Read data using io.Reader and upload it to Storj.
-	for err = io.ErrShortBuffer; err == io.ErrShortBuffer; {
-		t := time.Now()
-		timeNow := t.Format("2006-01-02_15:04:05")
-		var filename = databaseName + "/" + timeNow + ".bson"
-		//
-		fmt.Println("File path: ", configStorj.UploadPath+filename)
-		fmt.Println("\nUploading of the object to the Storj bucket: Initiated...")
-
-		err = bucket.UploadObject(ctx, configStorj.UploadPath+filename, databaseReader, nil)
-		if DEBUG {
-			fileNamesDEBUG = append(fileNamesDEBUG, filename)
-
-		}
+	t := time.Now()
+	timeNow := t.Format("2006-01-02_15:04:05")
+	var filename = client.CompressedKey(databaseName + "/" + timeNow + fileExtension)
+	//
+	fmt.Fprintln(client.log, "File path: ", client.uploadPrefix()+filename)
+	fmt.Fprintln(client.log, "\nUploading of the object to the Storj bucket: Initiated...")
+
+	object, err := client.Upload(ctx, client.uploadPrefix()+filename, databaseReader)
+	if manifest != nil {
+		manifest.Snapshot = databaseName + "/" + timeNow
+		manifest.addObject(path.Base(object.Path), object)
+	}
+	if DEBUG {
+		fileNamesDEBUG = append(fileNamesDEBUG, object.Path)
 	}
 
 	if err != nil {
-		fmt.Printf("Could not upload: %s\t", err)
-		return scope, err
+		fmt.Fprintf(client.log, "Could not upload: %s\t", err)
+		return err
 	}
 
-	fmt.Println("Uploading of the object to the Storj bucket: Completed!")
+	fmt.Fprintln(client.log, "Uploading of the object to the Storj bucket: Completed!")
 
 	if DEBUG {
-		for _, filename := range fileNamesDEBUG {
+		for _, objectPath := range fileNamesDEBUG {
 			// Test uploaded data by downloading it.
 			// serializedAccess, err := access.Serialize().
 			// Initiate a download of the same object again.
-		
-			fmt.Printf("Downloading Object %s from bucket : Initiated...\n", filename)
+
+			fmt.Fprintf(client.log, "Downloading Object %s from bucket : Initiated...\n", objectPath)
 			// Read everything from the stream.
-			receivedContents, err := downloadObject(ctx, bucket, configStorj.UploadPath + filename)
+			var receivedContents bytes.Buffer
+			_, err := client.Download(ctx, objectPath, &receivedContents)
 			if err != nil {
-				return scope, fmt.Errorf("could not download object: %v", err)
+				return err
 			}
 			var decodedBson bson.M
-			bson.Unmarshal(receivedContents, &decodedBson)
-			_, err = json.Marshal(decodedBson)
-			if err != nil {
-				panic(err) // it will be invoked
-				// panic: json: unsupported value: NaN
+			if err := bson.Unmarshal(receivedContents.Bytes(), &decodedBson); err != nil {
+				fmt.Fprintln(client.log, "Could not decode the downloaded object:", err)
+			} else if _, err := json.Marshal(decodedBson); err != nil {
+				// e.g. json: unsupported value: NaN
+				fmt.Fprintln(client.log, "Could not convert the downloaded object to JSON:", err)
 			}
-			path := strings.Split(filename, "/")
+			path := strings.Split(strings.TrimPrefix(objectPath, client.uploadPrefix()), "/")
 
 			pathtokens := strings.Split(path[1], ":")
-		_ = os.MkdirAll(filepath.Join("debug", path[0]), 0644)
+			_ = os.MkdirAll(filepath.Join("debug", path[0]), 0644)
 			var fileNameDownload = filepath.Join("debug", path[0], pathtokens[0], pathtokens[1], pathtokens[2])
 
-			err = ioutil.WriteFile(fileNameDownload, receivedContents, 0644)
+			err = ioutil.WriteFile(fileNameDownload, receivedContents.Bytes(), 0644)
 			if err != nil {
-				fmt.Println(err)
+				fmt.Fprintln(client.log, err)
 			}
 
-			fmt.Printf("Downloaded %d bytes of Object from bucket!\n", len(receivedContents))
+			fmt.Fprintf(client.log, "Downloaded %d bytes of Object from bucket!\n", receivedContents.Len())
 		}
 	}
 
-	return scope, nil
+	return nil
+}
+
+// DownloadData downloads the object at objectPath from the desired bucket
+// and streams its data into the given io.Writer.
+func (client *Client) DownloadData(ctx context.Context, objectPath string, databaseWriter io.Writer) (int64, error) {
+	// objectPath is the full path of the object within the bucket.
+	// databaseWriter is an io.Writer implementation that 'writes' the
+	// downloaded data into the desired destination.
+	fmt.Fprintf(client.log, "Downloading Object %s from bucket : Initiated...\n", objectPath)
+
+	numOfBytesDownloaded, err := client.Download(ctx, objectPath, databaseWriter)
+	if err != nil {
+		fmt.Fprintf(client.log, "Could not download: %s\t", err)
+		return numOfBytesDownloaded, err
+	}
+
+	fmt.Fprintf(client.log, "Downloaded %d bytes of Object from bucket!\n", numOfBytesDownloaded)
+
+	return numOfBytesDownloaded, nil
 }
 
-func downloadObject(ctx context.Context, bucket *uplink.Bucket, path string) ([]byte, error) {
+// uploadObject uploads the data of the reader, compressed with the codec,
+// as the object at the given path. It returns the reader of the object's
+// data, which has counted and hashed it.
+func uploadObject(ctx context.Context, bucket objectBucket, path string, reader io.Reader, codec string) (*hashingReader, error) {
+	var uploadOptions *uplink.UploadOptions
+	if codec != CompressionNone {
+		compressedReader := compressReader(reader, codec)
+		defer compressedReader.Close()
+
+		reader = compressedReader
+		uploadOptions = &uplink.UploadOptions{Metadata: map[string]string{compressionMetadataKey: codec}}
+	}
+
+	objectReader := newHashingReader(reader)
+	if err := bucket.UploadObject(ctx, path, objectReader, uploadOptions); err != nil {
+		return objectReader, &NetworkError{Op: "upload object", Path: path, Err: err}
+	}
+	return objectReader, nil
+}
+
+// downloadObject streams the object at the given path into the writer,
+// decompressing it as told by its path, and returns the number of bytes
+// written.
+func downloadObject(ctx context.Context, bucket objectBucket, path string, writer io.Writer) (int64, error) {
+	_, numOfBytesDownloaded, err := downloadHashedObject(ctx, bucket, path, writer)
+	return numOfBytesDownloaded, err
+}
+
+// downloadHashedObject downloads the object as downloadObject does. It also returns
+// the reader of the object's stored data, which has counted and hashed all of it.
+func downloadHashedObject(ctx context.Context, bucket objectBucket, path string, writer io.Writer) (*hashingReader, int64, error) {
 	strm, err := bucket.Download(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("could not open object at %q: %v", path, err)
+		return nil, 0, &NetworkError{Op: "open object", Path: path, Err: err}
 	}
 	defer strm.Close()
 
-	// Read everything from the stream.
-	receivedContents, err := ioutil.ReadAll(strm)
+	objectReader := newHashingReader(strm)
+	decompressedStrm, err := decompressReader(objectReader, compressionOfPath(path))
 	if err != nil {
-		return nil, fmt.Errorf("could not read object: %v", err)
+		return objectReader, 0, fmt.Errorf("could not decompress object at %q: %w", path, err)
 	}
+	defer decompressedStrm.Close()
 
-	return receivedContents, err
-}
\ No newline at end of file
+	// Copy everything from the stream.
+	numOfBytesDownloaded, err := io.Copy(writer, decompressedStrm)
+	if err != nil {
+		return objectReader, numOfBytesDownloaded, fmt.Errorf("could not read object: %w", err)
+	}
+
+	// Read any data left after the compressed stream, so that all of it is hashed.
+	if _, err := io.Copy(ioutil.Discard, objectReader); err != nil {
+		return objectReader, numOfBytesDownloaded, &NetworkError{Op: "download object", Path: path, Err: err}
+	}
+
+	return objectReader, numOfBytesDownloaded, nil
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2019 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// VerifySnapshot downloads every object listed by the manifest at manifestPath,
+// comparing their sizes and SHA-256 checksums with the ones of the manifest,
+// and streams the data of the data objects into the given io.Writer.
+// It returns the mismatches found, if any.
+func (client *Client) VerifySnapshot(ctx context.Context, manifestPath string, manifest *Manifest, dataWriter io.Writer) ([]error, error) {
+	// dataWriter is an io.Writer implementation that checks the data of the snapshot.
+	var mismatches []error
+	verifyObject := func(object ManifestObject, writer io.Writer) error {
+		objectPath := SnapshotObjectPath(manifestPath, object.Path)
+		fmt.Fprintf(client.log, "Verifying Object %s...\n", objectPath)
+
+		objectReader, _, err := downloadHashedObject(ctx, client.bucket, objectPath, writer)
+		if err != nil {
+			return err
+		}
+		if objectReader.size != object.Size {
+			mismatches = append(mismatches, fmt.Errorf("object %s: size is %d bytes, manifest records %d", objectPath, objectReader.size, object.Size))
+		}
+		if sum := objectReader.sum(); sum != object.SHA256 {
+			mismatches = append(mismatches, fmt.Errorf("object %s: SHA-256 is %s, manifest records %s", objectPath, sum, object.SHA256))
+		}
+		return nil
+	}
+
+	for _, object := range manifest.Objects {
+		if err := verifyObject(object, dataWriter); err != nil {
+			return mismatches, err
+		}
+	}
+	if manifest.Oplog != nil {
+		if err := verifyObject(manifest.Oplog.ManifestObject, ioutil.Discard); err != nil {
+			return mismatches, err
+		}
+	}
+
+	return mismatches, nil
+}